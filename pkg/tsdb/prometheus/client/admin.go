@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrAdminNotEnabled is returned by admin methods like DeleteSeries of clients created without WithAdminAPI.
+var ErrAdminNotEnabled = errors.New("admin API is not enabled on the client")
+
+// ErrAdminAPIDisabled is returned when the Prometheus server does not serve the admin API, e.g. because it runs
+// without --web.enable-admin-api.
+var ErrAdminAPIDisabled = errors.New("admin API is disabled on the Prometheus server")
+
+// WithAdminAPI enables the methods that call the admin API of Prometheus, like DeleteSeries. They return
+// ErrAdminNotEnabled otherwise, so data cannot be deleted by accident.
+func WithAdminAPI(enabled bool) Option {
+	return func(c *Client) {
+		c.adminAPI = enabled
+	}
+}
+
+// DeleteSeries deletes the data of the series that match any of the given matchers in the given time range. Zero
+// start or end times are not sent, so all data of the series is deleted. The data is only removed from disk by the
+// next compaction. It requires WithAdminAPI.
+func (c *Client) DeleteSeries(ctx context.Context, matchers []string, start, end time.Time) (*http.Response, error) {
+	if !c.adminAPI {
+		return nil, ErrAdminNotEnabled
+	}
+
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
+	req, err := c.createValuesRequest(ctx, http.MethodPost, c.apiEndpoint("admin/tsdb/delete_series"), matcherValues(matchers, start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkAdminError(res)
+}
+
+// checkAdminError returns ErrAdminAPIDisabled for responses of servers without the admin API. Prometheus answers
+// with an unavailable error, proxies and other stores usually with 404 or 405.
+func checkAdminError(res *http.Response) (*http.Response, error) {
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusMethodNotAllowed {
+		drainAndClose(res.Body)
+		return nil, fmt.Errorf("%w: status %d", ErrAdminAPIDisabled, res.StatusCode)
+	}
+
+	res, err := checkError(res)
+	var promErr *PrometheusError
+	if errors.As(err, &promErr) && promErr.Type == ErrorTypeUnavailable && strings.Contains(promErr.Message, "admin APIs disabled") {
+		return nil, fmt.Errorf("%w: %w", ErrAdminAPIDisabled, err)
+	}
+	return res, err
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+func TestDeleteSeries(t *testing.T) {
+	start, end := time.Unix(0, 0), time.Unix(1234, 0)
+
+	t.Run("posts matchers and time range to the admin endpoint", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusNoContent, "", nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithAdminAPI(true))
+
+		res, err := client.DeleteSeries(context.Background(), []string{`up{job="a"}`, "down"}, start, end)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+		require.Equal(t, http.MethodPost, doer.LastRequest().Method)
+		require.Equal(t, "/api/v1/admin/tsdb/delete_series", doer.LastRequest().URL.Path)
+		body, err := io.ReadAll(doer.LastRequest().Body)
+		require.NoError(t, err)
+		require.Equal(t, "end=1234&match%5B%5D=up%7Bjob%3D%22a%22%7D&match%5B%5D=down&start=0", string(body))
+	})
+
+	t.Run("requires the admin option", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusNoContent, "", nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+		_, err := client.DeleteSeries(context.Background(), []string{"up"}, start, end)
+		require.ErrorIs(t, err, ErrAdminNotEnabled)
+		require.Nil(t, doer.LastRequest())
+	})
+
+	t.Run("returns ErrAdminAPIDisabled for disabled admin APIs", func(t *testing.T) {
+		responses := map[string]clienttest.Responder{
+			"not found":          clienttest.Respond(http.StatusNotFound, "404 page not found", nil),
+			"method not allowed": clienttest.Status(http.StatusMethodNotAllowed),
+			"prometheus":         clienttest.Respond(http.StatusServiceUnavailable, `{"status":"error","errorType":"unavailable","error":"admin APIs disabled"}`, nil),
+		}
+		for name, respond := range responses {
+			t.Run(name, func(t *testing.T) {
+				client := newTestClient(t, clienttest.NewFakeDoer(respond), http.MethodGet, "http://localhost:9090", WithAdminAPI(true))
+
+				_, err := client.DeleteSeries(context.Background(), []string{"up"}, start, end)
+				require.ErrorIs(t, err, ErrAdminAPIDisabled)
+			})
+		}
+	})
+
+	t.Run("returns other errors as they are", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusBadRequest, `{"status":"error","errorType":"bad_data","error":"no match[] parameter provided"}`, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithAdminAPI(true))
+
+		_, err := client.DeleteSeries(context.Background(), nil, start, end)
+		var promErr *PrometheusError
+		require.ErrorAs(t, err, &promErr)
+		require.NotErrorIs(t, err, ErrAdminAPIDisabled)
+	})
+}
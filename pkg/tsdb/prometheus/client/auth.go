@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// TokenSource returns the bearer token for a request. Implementations should cache tokens and only refresh them
+// when they expire, as Token is called for every request.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// WithBasicAuth sets basic auth credentials on every request. WithBearerToken takes precedence if both are set.
+func WithBasicAuth(user, password string) Option {
+	return func(c *Client) {
+		c.basicAuth = &basicAuth{user: user, password: password}
+	}
+}
+
+// WithBearerToken sets a bearer token in the Authorization header of every request.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.bearerToken = token
+	}
+}
+
+// WithTokenSource fetches a bearer token for every request attempt, so retries pick up refreshed tokens. It takes
+// precedence over WithBearerToken and WithBasicAuth. Requests fail if no token can be fetched.
+func WithTokenSource(ts TokenSource) Option {
+	return func(c *Client) {
+		c.tokenSource = ts
+	}
+}
+
+type basicAuth struct {
+	user     string
+	password string
+}
+
+// setAuthorization sets the configured credentials. It runs before the request is passed to the doer, so a request
+// signer sees the final Authorization header.
+func (c *Client) setAuthorization(req *http.Request) {
+	switch {
+	case c.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	case c.basicAuth != nil:
+		req.SetBasicAuth(c.basicAuth.user, c.basicAuth.password)
+	}
+}
+
+type tokenDoer struct {
+	next   doer
+	source TokenSource
+}
+
+func (d *tokenDoer) Do(req *http.Request) (*http.Response, error) {
+	token, err := d.source.Token(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bearer token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return d.next.Do(req)
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestAuthorization(t *testing.T) {
+	query := &models.Query{Expr: "up", Start: time.Unix(0, 0), End: time.Unix(1234, 0), RangeQuery: true, Step: time.Second}
+	resourceReq := &backend.CallResourceRequest{Method: http.MethodGet, Path: "/api/v1/labels", URL: "/api/v1/labels"}
+
+	t.Run("sets basic auth", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithBasicAuth("user", "pass"))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		user, pass, ok := doer.LastRequest().BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", user)
+		require.Equal(t, "pass", pass)
+
+		res, err = client.QueryResource(context.Background(), resourceReq)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		_, _, ok = doer.LastRequest().BasicAuth()
+		require.True(t, ok)
+	})
+
+	t.Run("sets bearer token", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithBearerToken("secret"))
+
+		res, err := client.QueryResource(context.Background(), resourceReq)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "Bearer secret", doer.LastRequest().Header.Get("Authorization"))
+	})
+
+	t.Run("prefers bearer token over basic auth", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithBasicAuth("user", "pass"), WithBearerToken("secret"))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "Bearer secret", doer.LastRequest().Header.Get("Authorization"))
+	})
+
+	t.Run("sets header before request signer runs", func(t *testing.T) {
+		var signed string
+		signer := func(req *http.Request) error {
+			signed = req.Header.Get("Authorization")
+			return nil
+		}
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Echo), http.MethodGet, "http://localhost:9090", WithBearerToken("secret"), WithRequestSigner(signer))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "Bearer secret", signed)
+	})
+
+	t.Run("fetches token for every attempt", func(t *testing.T) {
+		ts := &countingTokenSource{}
+		doer := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable),
+			func(req *http.Request) (*http.Response, error) {
+				require.Equal(t, "Bearer token-2", req.Header.Get("Authorization"))
+				return clienttest.Status(http.StatusOK)(req)
+			},
+		))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithTokenSource(ts), WithBearerToken("static"), WithRetryPolicy(testPolicy))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, 2, ts.calls)
+	})
+
+	t.Run("aborts request when token cannot be fetched", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		ts := &countingTokenSource{err: errors.New("token endpoint unavailable")}
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithTokenSource(ts))
+
+		_, err := client.QueryRange(context.Background(), query)
+		require.EqualError(t, err, "failed to fetch bearer token: token endpoint unavailable")
+		require.Nil(t, doer.LastRequest())
+	})
+}
+
+type countingTokenSource struct {
+	calls int
+	err   error
+}
+
+func (ts *countingTokenSource) Token(ctx context.Context) (string, error) {
+	if ts.err != nil {
+		return "", ts.err
+	}
+	ts.calls++
+	return fmt.Sprintf("token-%d", ts.calls), nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+const defaultBatchConcurrency = 8
+
+// BatchResult is the result of one query of a batch.
+type BatchResult struct {
+	Result
+	Err error
+}
+
+// WithBatchConcurrency sets how many queries of a batch run at the same time. A value of zero or less uses the
+// default of 8.
+func WithBatchConcurrency(n int) Option {
+	return func(c *Client) {
+		if n <= 0 {
+			n = defaultBatchConcurrency
+		}
+		c.batchConcurrency = n
+	}
+}
+
+// QueryRangeBatch runs range queries concurrently and returns their results in the order of the queries. Failed
+// queries have their error set on the result. Canceling ctx aborts all queries that are running, queries that did not
+// start yet are not sent, and ctx's error is returned.
+func (c *Client) QueryRangeBatch(ctx context.Context, queries []*models.Query) ([]*BatchResult, error) {
+	results := make([]*BatchResult, len(queries))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(c.batchConcurrency, len(queries)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				res, err := c.QueryRangeResult(ctx, queries[i])
+				if err != nil {
+					results[i] = &BatchResult{Err: err}
+					continue
+				}
+				results[i] = &BatchResult{Result: *res}
+			}
+		}()
+	}
+
+	var err error
+send:
+	for i := range queries {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break send
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err != nil {
+		for i := range results {
+			if results[i] == nil {
+				results[i] = &BatchResult{Err: err}
+			}
+		}
+	}
+	return results, err
+}
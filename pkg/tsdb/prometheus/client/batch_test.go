@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestQueryRangeBatch(t *testing.T) {
+	queries := func(exprs ...string) []*models.Query {
+		var qs []*models.Query
+		for _, expr := range exprs {
+			q := *rangeQuery
+			q.Expr = expr
+			qs = append(qs, &q)
+		}
+		return qs
+	}
+
+	t.Run("returns results in query order", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			expr := req.URL.Query().Get("query")
+			if expr == "fail" {
+				return &http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(
+					`{"status":"error","errorType":"bad_data","error":"invalid"}`))}, nil
+			}
+			if expr == "slow" {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(matrixResponse))}, nil
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithBatchConcurrency(2))
+
+		results, err := client.QueryRangeBatch(context.Background(), queries("slow", "fail", "up"))
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		require.NoError(t, results[0].Err)
+		require.Len(t, results[0].Frames, 2)
+		var promErr *PrometheusError
+		require.ErrorAs(t, results[1].Err, &promErr)
+		require.NoError(t, results[2].Err)
+		require.Len(t, results[2].Frames, 2)
+	})
+
+	t.Run("limits concurrency", func(t *testing.T) {
+		var running, maxRunning int32
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(matrixResponse))}, nil
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithBatchConcurrency(2))
+
+		results, err := client.QueryRangeBatch(context.Background(), queries("a", "b", "c", "d", "e"))
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+		require.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(2))
+	})
+
+	t.Run("aborts queries when context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var started sync.WaitGroup
+		started.Add(1)
+		var once sync.Once
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			once.Do(started.Done)
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithBatchConcurrency(1))
+
+		go func() {
+			started.Wait()
+			cancel()
+		}()
+		results, err := client.QueryRangeBatch(ctx, queries("a", "b", "c"))
+		require.ErrorIs(t, err, context.Canceled)
+		require.Len(t, results, 3)
+		for _, r := range results {
+			require.ErrorIs(t, r.Err, context.Canceled)
+		}
+	})
+}
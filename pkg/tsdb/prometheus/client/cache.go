@@ -0,0 +1,219 @@
+package client
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+// cacheNowTolerance is how close to the current time the end of a query can be for it to be considered a query up to
+// now, which is never cached as its result changes on every refresh.
+const cacheNowTolerance = time.Minute
+
+// CacheHeader is set to "hit" on responses served from the cache, so callers of QueryRange can tell them apart.
+const CacheHeader = "X-Grafana-Cache"
+
+const cacheHit = "hit"
+
+// Cache stores response bodies of range queries. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value of the key, if it is cached and has not expired.
+	Get(key string) ([]byte, bool)
+	// Set caches the value for the given time.
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// WithCache caches successful JSON responses of range queries for the given time. Queries that end close to the
+// current time are not cached, as their result changes on every dashboard refresh.
+func WithCache(cache Cache, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cache = cache
+		c.cacheTTL = ttl
+	}
+}
+
+// cacheKey returns the key of a range query. Besides the URL of the query with its parameters in sorted order, it
+// covers everything else the response can depend on: the Host override, the headers of the context like tenant IDs,
+// the query tags and the credentials. Responses are thus never served to another server, tenant or user. These are
+// hashed, so credentials are not stored in the cache. It reports false if the key cannot be computed because no token
+// could be fetched; the request then fails anyway.
+func (c *Client) cacheKey(ctx context.Context, endpoint string, qv map[string]string, tags map[string]string) (string, bool) {
+	v := make(url.Values, len(qv))
+	for key, val := range qv {
+		v.Set(key, val)
+	}
+	u, err := c.createUrl(endpoint, v)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	writeKeyPart := func(parts ...string) {
+		for _, part := range parts {
+			// Length prefixes keep the boundaries of the parts, so different parts do not hash alike.
+			_, _ = fmt.Fprintf(h, "%d:%s", len(part), part)
+		}
+	}
+	if u.User != nil {
+		writeKeyPart("user", u.User.String())
+		u.User = nil
+	}
+	writeKeyPart("host", c.host)
+
+	headers := headersFromContext(ctx)
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeKeyPart("header", http.CanonicalHeaderKey(name))
+		writeKeyPart(headers[name]...)
+	}
+	writeKeyPart("tags", queryTagsValue(tags))
+
+	if c.basicAuth != nil {
+		writeKeyPart("basic", c.basicAuth.user, c.basicAuth.password)
+	}
+	writeKeyPart("bearer", c.bearerToken)
+	if c.tokenSource != nil {
+		token, err := c.tokenSource.Token(ctx)
+		if err != nil {
+			return "", false
+		}
+		writeKeyPart("token", token)
+	}
+
+	return u.String() + "#" + hex.EncodeToString(h.Sum(nil)), true
+}
+
+type noCacheKey struct{}
+
+// WithNoCache returns a context for which range queries skip the cache lookup, e.g. for a forced refresh. Their
+// results are still stored in the cache, so later queries get the fresh result.
+func WithNoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func noCache(ctx context.Context) bool {
+	skip, _ := ctx.Value(noCacheKey{}).(bool)
+	return skip
+}
+
+// cacheable reports whether the result of the query can be cached.
+func (c *Client) cacheable(q *models.Query) bool {
+	return c.cache != nil && c.cacheTTL > 0 && c.since(q.End) > cacheNowTolerance
+}
+
+// cachedResponse returns a response with the cached body.
+func cachedResponse(body []byte) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"application/json"}, CacheHeader: {cacheHit}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+// storeResponse reads a successful JSON response into the cache. It returns a response that reads the cached body.
+func (c *Client) storeResponse(key string, res *http.Response) (*http.Response, error) {
+	if res.StatusCode < 200 || res.StatusCode >= 300 || checkContentType(res) != nil {
+		return res, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Set(key, body, c.cacheTTL)
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// LRUCache is a Cache that holds a limited number of entries in memory, evicting the least recently used ones.
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// LRUCacheOption configures an LRUCache.
+type LRUCacheOption func(*LRUCache)
+
+// WithLRUClock sets the function the expiry of entries is computed with, instead of time.Now. Use the clock set with
+// WithClock for the clients sharing the cache. A nil function keeps time.Now.
+func WithLRUClock(now func() time.Time) LRUCacheOption {
+	return func(c *LRUCache) {
+		if now != nil {
+			c.now = now
+		}
+	}
+}
+
+// NewLRUCache returns a cache holding up to size entries.
+func NewLRUCache(size int, opts ...LRUCacheOption) *LRUCache {
+	c := &LRUCache{size: size, entries: map[string]*list.Element{}, order: list.New(), now: time.Now}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if c.now().After(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: c.now().Add(ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
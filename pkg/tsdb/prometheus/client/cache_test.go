@@ -0,0 +1,225 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestCache(t *testing.T) {
+	pastQuery := &models.Query{
+		Expr:       "up",
+		Start:      time.Unix(1641889530, 0),
+		End:        time.Unix(1641889538, 0),
+		RangeQuery: true,
+		Step:       1 * time.Second,
+	}
+
+	t.Run("returns cached response for identical query", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithCache(NewLRUCache(10), time.Minute))
+
+		for i := 0; i < 2; i++ {
+			res, err := client.QueryRange(context.Background(), pastQuery)
+			require.NoError(t, err)
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, matrixResponse, string(body))
+		}
+		require.Equal(t, 1, doer.Calls())
+
+		frames, err := client.QueryRangeFrames(context.Background(), pastQuery)
+		require.NoError(t, err)
+		require.Len(t, frames, 2)
+		require.Equal(t, 1, doer.Calls())
+	})
+
+	t.Run("marks cached results", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithCache(NewLRUCache(10), time.Minute))
+
+		result, err := client.QueryRangeResult(context.Background(), pastQuery)
+		require.NoError(t, err)
+		require.False(t, result.FromCache)
+
+		result, err = client.QueryRangeResult(context.Background(), pastQuery)
+		require.NoError(t, err)
+		require.True(t, result.FromCache)
+		require.Len(t, result.Frames, 2)
+
+		res, err := client.QueryRange(context.Background(), pastQuery)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "hit", res.Header.Get(CacheHeader))
+	})
+
+	t.Run("does not cache queries up to now", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithCache(NewLRUCache(10), time.Minute))
+		q := &models.Query{Expr: "up", Start: time.Now().Add(-time.Hour), End: time.Now(), RangeQuery: true, Step: time.Minute}
+
+		for i := 0; i < 2; i++ {
+			res, err := client.QueryRange(context.Background(), q)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+		}
+		require.Equal(t, 2, doer.Calls())
+	})
+
+	t.Run("skips lookup but stores result with WithNoCache", func(t *testing.T) {
+		cache := NewLRUCache(10)
+		fresh := strings.Replace(matrixResponse, `"status": "success",`, `"status": "success", "warnings": ["fresh"],`, 1)
+		doer := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Respond(http.StatusOK, matrixResponse, nil), clienttest.Respond(http.StatusOK, fresh, nil)))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithCache(cache, time.Minute))
+
+		result, err := client.QueryRangeResult(context.Background(), pastQuery)
+		require.NoError(t, err)
+		require.False(t, result.FromCache)
+
+		result, err = client.QueryRangeResult(WithNoCache(context.Background()), pastQuery)
+		require.NoError(t, err)
+		require.False(t, result.FromCache)
+		require.Equal(t, []string{"fresh"}, result.Warnings)
+		require.Equal(t, 2, doer.Calls())
+
+		result, err = client.QueryRangeResult(context.Background(), pastQuery)
+		require.NoError(t, err)
+		require.True(t, result.FromCache)
+		require.Equal(t, []string{"fresh"}, result.Warnings)
+		require.Equal(t, 2, doer.Calls())
+	})
+
+	// echo responds with the tenant and host of the request, so tests can tell which request a response belongs to.
+	calls := 0
+	echo := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+		calls++
+		body := fmt.Sprintf(`{"status":"success","data":{"resultType":"matrix","result":[]},"tenant":"%s@%s"}`, req.Header.Get("X-Scope-OrgID"), req.URL.Host)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+	queryBody := func(t *testing.T, client *Client, ctx context.Context) string {
+		res, err := client.QueryRange(ctx, pastQuery)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return string(body)
+	}
+
+	t.Run("keeps responses of tenants apart", func(t *testing.T) {
+		calls = 0
+		client := newTestClient(t, echo, http.MethodGet, "http://a:9090", WithCache(NewLRUCache(10), time.Minute))
+		t1 := WithHeaders(context.Background(), http.Header{"X-Scope-OrgID": {"t1"}})
+		t2 := WithHeaders(context.Background(), http.Header{"X-Scope-OrgID": {"t2"}})
+
+		require.Contains(t, queryBody(t, client, t1), `"tenant":"t1@a:9090"`)
+		require.Contains(t, queryBody(t, client, t2), `"tenant":"t2@a:9090"`)
+		require.Contains(t, queryBody(t, client, t1), `"tenant":"t1@a:9090"`)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("keeps responses of base URLs apart", func(t *testing.T) {
+		calls = 0
+		cache := NewLRUCache(10)
+		a := newTestClient(t, echo, http.MethodGet, "http://a:9090", WithCache(cache, time.Minute))
+		b, err := a.WithBaseURL("http://b")
+		require.NoError(t, err)
+
+		require.Contains(t, queryBody(t, a, context.Background()), `"tenant":"@a:9090"`)
+		require.Contains(t, queryBody(t, b, context.Background()), `"tenant":"@b"`)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("keeps responses of credentials and query tags apart", func(t *testing.T) {
+		calls = 0
+		cache := NewLRUCache(10)
+		alice := newTestClient(t, echo, http.MethodGet, "http://a:9090", WithCache(cache, time.Minute), WithBasicAuth("alice", "secret"))
+		bob := newTestClient(t, echo, http.MethodGet, "http://a:9090", WithCache(cache, time.Minute), WithBasicAuth("bob", "secret"))
+		queryBody(t, alice, context.Background())
+		queryBody(t, bob, context.Background())
+		require.Equal(t, 2, calls)
+
+		tagged := *pastQuery
+		tagged.QueryTags = map[string]string{"dashboard": "a"}
+		res, err := alice.QueryRange(context.Background(), &tagged)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("does not cache failed responses", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusBadGateway, "bad gateway", nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithCache(NewLRUCache(10), time.Minute))
+
+		for i := 0; i < 2; i++ {
+			_, err := client.QueryRange(context.Background(), pastQuery)
+			require.ErrorContains(t, err, "502 Bad Gateway")
+		}
+		require.Equal(t, 2, doer.Calls())
+	})
+
+	t.Run("does not cache non JSON responses", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, "ok", http.Header{"Content-Type": {"text/plain"}}))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithCache(NewLRUCache(10), time.Minute))
+
+		for i := 0; i < 2; i++ {
+			res, err := client.QueryRange(context.Background(), pastQuery)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+		}
+		require.Equal(t, 2, doer.Calls())
+	})
+}
+
+func TestLRUCache(t *testing.T) {
+	t.Run("evicts least recently used entries", func(t *testing.T) {
+		cache := NewLRUCache(2)
+		cache.Set("a", []byte("1"), time.Minute)
+		cache.Set("b", []byte("2"), time.Minute)
+		_, ok := cache.Get("a")
+		require.True(t, ok)
+		cache.Set("c", []byte("3"), time.Minute)
+
+		_, ok = cache.Get("b")
+		require.False(t, ok)
+		v, ok := cache.Get("a")
+		require.True(t, ok)
+		require.Equal(t, []byte("1"), v)
+		_, ok = cache.Get("c")
+		require.True(t, ok)
+	})
+
+	t.Run("expires entries after ttl", func(t *testing.T) {
+		cache := NewLRUCache(2)
+		cache.Set("a", []byte("1"), -time.Second)
+		_, ok := cache.Get("a")
+		require.False(t, ok)
+	})
+
+	t.Run("expires entries by the clock", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(1641889530, 0)}
+		cache := NewLRUCache(2, WithLRUClock(clock.now))
+		cache.Set("a", []byte("1"), time.Minute)
+
+		clock.t = clock.t.Add(time.Minute)
+		_, ok := cache.Get("a")
+		require.True(t, ok)
+
+		clock.t = clock.t.Add(time.Second)
+		_, ok = cache.Get("a")
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending the request while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open, Prometheus is failing")
+
+// CircuitBreakerPolicy configures when the circuit breaker opens. Network errors and 5xx responses are failures,
+// other responses like 4xx errors of invalid queries show that the server is working.
+type CircuitBreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failures that open the circuit. Values below 1 disable the breaker.
+	FailureThreshold int
+	// Cooldown is how long requests fail with ErrCircuitOpen once the circuit opened. After it, one request is let
+	// through to test whether the server recovered. It closes the circuit if it succeeds and opens it again otherwise.
+	Cooldown time.Duration
+}
+
+// WithCircuitBreaker fails requests fast with ErrCircuitOpen while Prometheus is failing, instead of sending every
+// request to a server that is down. Retries of a request count as one failure, and so do requests that time out, but
+// not requests the caller canceled. Every base URL has its own breaker, so a failing hedge URL, query URL or server
+// of a copy created with WithBaseURL does not fail the requests to the others. Copies for the same URL share it.
+func WithCircuitBreaker(p CircuitBreakerPolicy) Option {
+	return func(c *Client) {
+		c.circuitBreaker = &p
+	}
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuitDoer struct {
+	next   doer
+	policy CircuitBreakerPolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitDoer(next doer, policy CircuitBreakerPolicy) *circuitDoer {
+	return &circuitDoer{next: next, policy: policy, now: time.Now}
+}
+
+// circuitBreakers holds the breakers of the base URLs of a client and its copies.
+type circuitBreakers struct {
+	next   doer
+	policy CircuitBreakerPolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	breakers map[string]*circuitDoer
+}
+
+func newCircuitBreakers(next doer, policy CircuitBreakerPolicy, now func() time.Time) *circuitBreakers {
+	return &circuitBreakers{next: next, policy: policy, now: now, breakers: map[string]*circuitDoer{}}
+}
+
+// forURL returns the breaker of a base URL, creating it on first use.
+func (b *circuitBreakers) forURL(u *url.URL) *circuitDoer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := u.Scheme + "://" + u.Host + u.Path
+	d, ok := b.breakers[key]
+	if !ok {
+		d = newCircuitDoer(b.next, b.policy)
+		d.now = b.now
+		b.breakers[key] = d
+	}
+	return d
+}
+
+func (d *circuitDoer) Do(req *http.Request) (*http.Response, error) {
+	if !d.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	res, err := d.next.Do(req)
+	// Requests the caller canceled tell nothing about the server. Expired deadlines do count, as a server that hangs
+	// is failing.
+	if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+		d.release()
+		return res, err
+	}
+	d.record(err != nil || res.StatusCode >= http.StatusInternalServerError)
+	return res, err
+}
+
+// allow reports whether a request may be sent. Once the cooldown passed, only one request is let through until it
+// finished.
+func (d *circuitDoer) allow() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch d.state {
+	case circuitOpen:
+		if d.now().Sub(d.openedAt) < d.policy.Cooldown {
+			return false
+		}
+		d.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// release lets another request test the server if a test request ended without a result.
+func (d *circuitDoer) release() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state == circuitHalfOpen {
+		d.state = circuitOpen
+		d.openedAt = time.Time{}
+	}
+}
+
+func (d *circuitDoer) record(failed bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !failed {
+		d.state = circuitClosed
+		d.failures = 0
+		return
+	}
+
+	d.failures++
+	if d.state == circuitHalfOpen || d.failures >= d.policy.FailureThreshold {
+		d.state = circuitOpen
+		d.openedAt = d.now()
+	}
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	policy := CircuitBreakerPolicy{FailureThreshold: 2, Cooldown: time.Minute}
+
+	newBreaker := func(responses ...clienttest.Responder) (*circuitDoer, *clienttest.FakeDoer, *time.Time) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(responses...))
+		now := time.Unix(0, 0)
+		d := newCircuitDoer(next, policy)
+		d.now = func() time.Time { return now }
+		return d, next, &now
+	}
+	send := func(d doer) error {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+		res, err := d.Do(req)
+		if err == nil {
+			require.NoError(t, res.Body.Close())
+		}
+		return err
+	}
+
+	t.Run("opens after consecutive failures", func(t *testing.T) {
+		d, next, _ := newBreaker(clienttest.Fail(errors.New("connection refused")), clienttest.Status(http.StatusServiceUnavailable))
+
+		require.Error(t, send(d))
+		require.NoError(t, send(d))
+		require.ErrorIs(t, send(d), ErrCircuitOpen)
+		require.Equal(t, 2, next.Calls())
+	})
+
+	t.Run("does not count client errors", func(t *testing.T) {
+		d, next, _ := newBreaker(clienttest.Status(http.StatusBadGateway), clienttest.Status(http.StatusBadRequest), clienttest.Status(http.StatusBadGateway), clienttest.Status(http.StatusOK))
+
+		for i := 0; i < 4; i++ {
+			require.NoError(t, send(d))
+		}
+		require.Equal(t, 4, next.Calls())
+	})
+
+	t.Run("half-opens after cooldown", func(t *testing.T) {
+		d, next, now := newBreaker(clienttest.Status(http.StatusBadGateway), clienttest.Status(http.StatusBadGateway), clienttest.Status(http.StatusOK), clienttest.Status(http.StatusOK))
+
+		require.NoError(t, send(d))
+		require.NoError(t, send(d))
+		require.ErrorIs(t, send(d), ErrCircuitOpen)
+
+		*now = now.Add(time.Minute)
+		require.NoError(t, send(d))
+		require.NoError(t, send(d))
+		require.Equal(t, 4, next.Calls())
+	})
+
+	t.Run("opens again if the test request fails", func(t *testing.T) {
+		d, next, now := newBreaker(clienttest.Status(http.StatusBadGateway), clienttest.Status(http.StatusBadGateway), clienttest.Status(http.StatusBadGateway))
+
+		require.NoError(t, send(d))
+		require.NoError(t, send(d))
+		*now = now.Add(time.Minute)
+		require.NoError(t, send(d))
+		require.ErrorIs(t, send(d), ErrCircuitOpen)
+		require.Equal(t, 3, next.Calls())
+	})
+
+	t.Run("lets only one request through while half-open", func(t *testing.T) {
+		d, _, now := newBreaker(clienttest.Status(http.StatusBadGateway), clienttest.Status(http.StatusBadGateway))
+		require.NoError(t, send(d))
+		require.NoError(t, send(d))
+
+		*now = now.Add(time.Minute)
+		require.True(t, d.allow())
+		require.False(t, d.allow())
+	})
+
+	t.Run("does not count canceled requests", func(t *testing.T) {
+		d, next, _ := newBreaker(clienttest.Fail(context.Canceled), clienttest.Fail(context.Canceled), clienttest.Status(http.StatusOK))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for i := 0; i < 2; i++ {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+			require.NoError(t, err)
+			_, err = d.Do(req)
+			require.ErrorIs(t, err, context.Canceled)
+		}
+		require.NoError(t, send(d))
+		require.Equal(t, 3, next.Calls())
+	})
+
+	t.Run("counts timed out requests", func(t *testing.T) {
+		d, next, _ := newBreaker(clienttest.Fail(context.DeadlineExceeded), clienttest.Fail(context.DeadlineExceeded))
+		ctx, cancel := context.WithDeadline(context.Background(), time.Unix(0, 0))
+		defer cancel()
+
+		for i := 0; i < 2; i++ {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+			require.NoError(t, err)
+			_, err = d.Do(req)
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+		}
+		require.ErrorIs(t, send(d), ErrCircuitOpen)
+		require.Equal(t, 2, next.Calls())
+	})
+
+	t.Run("keeps breakers of base URLs apart", func(t *testing.T) {
+		calls := map[string]int{}
+		next := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			calls[req.URL.Host]++
+			if req.URL.Host == "a:9090" {
+				return clienttest.Status(http.StatusServiceUnavailable)(req)
+			}
+			return clienttest.Status(http.StatusOK)(req)
+		})
+		a := newTestClient(t, next, http.MethodGet, "http://a:9090", WithCircuitBreaker(CircuitBreakerPolicy{FailureThreshold: 1, Cooldown: time.Minute}))
+		b, err := a.WithBaseURL("http://b:9090")
+		require.NoError(t, err)
+		sameAsA, err := b.WithBaseURL("http://a:9090")
+		require.NoError(t, err)
+
+		res, err := a.Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		_, err = a.Alerts(context.Background())
+		require.ErrorIs(t, err, ErrCircuitOpen)
+		_, err = sameAsA.Alerts(context.Background())
+		require.ErrorIs(t, err, ErrCircuitOpen)
+
+		res, err = b.Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, map[string]int{"a:9090": 1, "b:9090": 1}, calls)
+	})
+
+	t.Run("fails client requests fast", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable)))
+		client := newTestClient(t, next, http.MethodGet, "http://localhost:9090", WithCircuitBreaker(CircuitBreakerPolicy{FailureThreshold: 1, Cooldown: time.Minute}))
+
+		res, err := client.Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		_, err = client.Alerts(context.Background())
+		require.ErrorIs(t, err, ErrCircuitOpen)
+		require.Equal(t, 1, next.Calls())
+	})
+}
@@ -2,7 +2,10 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -10,12 +13,22 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/prometheus/common/model"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
 )
 
+// ErrClientClosed is returned for requests of a client that was closed.
+var ErrClientClosed = errors.New("client closed")
+
+// ErrNotSupported is returned when the Prometheus server does not provide an endpoint, e.g. because it is too old.
+var ErrNotSupported = errors.New("endpoint not supported by Prometheus")
+
 type doer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
@@ -26,31 +39,433 @@ type doer interface {
 type Client struct {
 	doer    doer
 	method  string
-	baseUrl string
+	baseURL *url.URL
+
+	userAgent   string
+	host        string
+	apiPrefix   string
+	retryPolicy *RetryPolicy
+	tracer      trace.Tracer
+	metrics     *Metrics
+	signer      RequestSigner
+	debugHook   func(RequestInfo)
+	rawBody     bool
+	logger      log.Logger
+	basicAuth   *basicAuth
+	bearerToken string
+	tokenSource TokenSource
+	cache       Cache
+	cacheTTL    time.Duration
+	hedgeURLs   []string
+
+	requestLogger func(RequestLog)
+	alignToStep   bool
+
+	// rangeURL and instantURL are the base URLs of range and instant queries if they differ from baseURL.
+	rangeURL   string
+	instantURL string
+
+	// rangeClient, instantClient and hedgeClients are the copies of the client sending requests to the range,
+	// instant and hedge URLs. NewClient creates them, so the URLs are parsed once.
+	rangeClient   *Client
+	instantClient *Client
+	hedgeClients  []*Client
+
+	postContentType string
+	transport       transportOptions
+
+	batchConcurrency int
+	rawValues        bool
+	histogramTotals  bool
+	sortSeries       bool
+	validate         bool
+	maxPoints        int
+
+	disableAcceptEncoding bool
+	escapeLabelNames      bool
+	jsonMatchers          bool
+	circuitBreaker        *CircuitBreakerPolicy
+	breakers              *circuitBreakers
+	resourcePrefix        string
+	getFallback           bool
+	percentEncodedSpaces  bool
+	corruptGzipFallback   bool
+	adminAPI              bool
+	tableFrames           bool
+	responseHeaders       []string
+	zstd                  bool
+	now                   func() time.Time
+
+	// root is canceled by Close to abort all requests of the client.
+	root      context.Context
+	closeRoot context.CancelFunc
+
+	slowQueryThreshold     time.Duration
+	largeResponseThreshold int64
+
+	// defaultTimeout bounds requests whose context has no deadline, zero means no bound.
+	defaultTimeout time.Duration
+
+	// compressThreshold is the size in bytes above which POST bodies are gzipped, zero disables compression.
+	compressThreshold int
+	// maxDecompressedSize limits the size of decompressed response bodies.
+	maxDecompressedSize int64
+	// autoMethodThreshold is the encoded query length in bytes above which AutoMethod switches from GET to POST.
+	autoMethodThreshold int
+}
+
+const (
+	defaultUserAgent         = "Grafana/prometheus-client"
+	defaultAPIPrefix         = "/api/v1"
+	defaultCompressThreshold = 1024
+
+	defaultAutoMethodThreshold = 2000
+)
+
+// AutoMethod can be passed as method to NewClient to send queries as GET while their encoded parameters are short
+// and as POST once they exceed the threshold set with WithAutoMethodThreshold, so long expressions do not run into
+// URL length limits of the server or proxies.
+const AutoMethod = "AUTO"
+
+// Option configures optional behaviour of the Client.
+type Option func(*Client)
+
+// WithHost sends every request with the given Host header instead of the host of the URL, e.g. for load balancers
+// routing by host name. The connection still goes to the host of the URL. An empty host keeps the default.
+func WithHost(host string) Option {
+	return func(c *Client) {
+		c.host = host
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request. An empty value keeps the default.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		if userAgent != "" {
+			c.userAgent = userAgent
+		}
+	}
+}
+
+// WithAPIPrefix sets the path of the HTTP API relative to the base URL, for Prometheus instances behind a proxy that
+// rewrites /api/v1. Resource calls are not affected as they carry their full path, see WithResourcePrefix.
+func WithAPIPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.apiPrefix = prefix
+	}
+}
+
+// WithResourcePrefix prepends prefix to the path of resource calls, for Prometheus instances mounted under a subpath
+// that is not part of the base URL, e.g. /prometheus turns /api/v1/series into /prometheus/api/v1/series.
+func WithResourcePrefix(prefix string) Option {
+	return func(c *Client) {
+		c.resourcePrefix = prefix
+	}
+}
+
+// WithPercentEncodedSpaces encodes spaces in query strings as %20 instead of +, for backends that take + in query
+// strings literally. Form encoded POST bodies are not affected.
+func WithPercentEncodedSpaces(enabled bool) Option {
+	return func(c *Client) {
+		c.percentEncodedSpaces = enabled
+	}
+}
+
+// WithRequestCompression gzips POST query bodies larger than threshold bytes, so long expressions and series
+// matchers stay below the body size limits of reverse proxies. A threshold of zero or less uses the default of 1KB.
+func WithRequestCompression(threshold int) Option {
+	return func(c *Client) {
+		if threshold <= 0 {
+			threshold = defaultCompressThreshold
+		}
+		c.compressThreshold = threshold
+	}
+}
+
+// WithAutoMethodThreshold sets the encoded query length in bytes above which AutoMethod sends queries as POST. A
+// threshold of zero or less uses the default of 2000 bytes.
+func WithAutoMethodThreshold(threshold int) Option {
+	return func(c *Client) {
+		if threshold <= 0 {
+			threshold = defaultAutoMethodThreshold
+		}
+		c.autoMethodThreshold = threshold
+	}
+}
+
+// WithDefaultTimeout bounds every request whose context has no deadline to the given timeout, so a hanging server
+// cannot block a caller forever. The timeout covers reading the response body, so it only ends when the body is closed.
+// Contexts that already have a deadline are left alone.
+func WithDefaultTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.defaultTimeout = timeout
+	}
+}
+
+// WithRawBody returns the body of resource calls exactly as received, with its Content-Encoding header intact, so it
+// can be proxied to the browser without decompressing it. Queries are always decompressed, as their bodies are parsed.
+func WithRawBody(raw bool) Option {
+	return func(c *Client) {
+		c.rawBody = raw
+	}
+}
+
+// WithLogger sets the logger used for warnings of the client.
+func WithLogger(logger log.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithAlignToStep rounds the start of range queries down and their end up to a multiple of the step, so the range
+// covers the whole requested time and identical ranges are cached well by the server. By default both are rounded
+// down.
+func WithAlignToStep(enabled bool) Option {
+	return func(c *Client) {
+		c.alignToStep = enabled
+	}
+}
+
+// WithPostContentType overrides the Content-Type header of POST range and instant queries, e.g. to add or remove a
+// charset for gateways that only accept one of them. Other form POST endpoints, like Series, and resource calls keep
+// their content type.
+func WithPostContentType(contentType string) Option {
+	return func(c *Client) {
+		c.postContentType = contentType
+	}
+}
+
+// WithQueryURLs sends range and instant queries to other base URLs than the other requests, e.g. expensive range
+// queries to a read replica. Empty URLs use the base URL of the client. Hedge URLs do not apply to queries sent to
+// another URL.
+func WithQueryURLs(rangeURL, instantURL string) Option {
+	return func(c *Client) {
+		c.rangeURL = rangeURL
+		c.instantURL = instantURL
+	}
+}
+
+// NewClient creates a client sending requests to the Prometheus server at baseUrl with d. If d is nil, the client
+// creates its own HTTP client, which can be tuned with the transport options. An error is returned if baseUrl or one
+// of the URLs set with the options is not an absolute URL.
+func NewClient(d doer, method, baseUrl string, opts ...Option) (*Client, error) {
+	u, err := parseBaseURL(baseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		doer:                d,
+		method:              method,
+		baseURL:             u,
+		userAgent:           defaultUserAgent,
+		apiPrefix:           defaultAPIPrefix,
+		autoMethodThreshold: defaultAutoMethodThreshold,
+		maxDecompressedSize: defaultMaxDecompressedSize,
+		logger:              backend.NewLoggerWith("logger", "tsdb.prometheus.client"),
+		batchConcurrency:    defaultBatchConcurrency,
+		maxPoints:           maxResolution,
+		now:                 time.Now,
+	}
+	c.root, c.closeRoot = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.doer == nil {
+		c.doer = newHTTPClient(c.transport)
+	} else if c.transport.roundTripper != nil {
+		c.logger.Warn("Both a doer and a transport are configured, using the doer")
+	}
+
+	if c.basicAuth != nil && c.bearerToken != "" {
+		c.logger.Warn("Both basic auth and bearer token are configured, using bearer token")
+	}
+
+	if c.signer != nil {
+		c.doer = &signingDoer{next: c.doer, signer: c.signer}
+	}
+
+	// The token is fetched before signing, so the signature covers the Authorization header.
+	if c.tokenSource != nil {
+		c.doer = &tokenDoer{next: c.doer, source: c.tokenSource}
+	}
+
+	if c.metrics != nil {
+		c.doer = &instrumentedDoer{next: c.doer, metrics: c.metrics, now: c.now}
+	}
+
+	if c.retryPolicy != nil {
+		retry := newRetryDoer(c.doer, *c.retryPolicy)
+		retry.now = c.now
+		c.doer = retry
+	}
+
+	// The breaker wraps the retries, so the attempts of a request count as one failure.
+	if c.circuitBreaker != nil && c.circuitBreaker.FailureThreshold > 0 {
+		c.breakers = newCircuitBreakers(c.doer, *c.circuitBreaker, c.now)
+		c.doer = c.breakers.forURL(c.baseURL)
+	}
+
+	if err := c.createReplicas(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// createReplicas creates the copies of the client for the range, instant and hedge URLs.
+func (c *Client) createReplicas() error {
+	if len(c.hedgeURLs) > 0 {
+		c.hedgeClients = []*Client{c.withBaseURL(c.baseURL)}
+		for _, hedgeURL := range c.hedgeURLs {
+			u, err := parseBaseURL(hedgeURL)
+			if err != nil {
+				return err
+			}
+			c.hedgeClients = append(c.hedgeClients, c.withBaseURL(u))
+		}
+	}
+
+	if c.rangeURL != "" {
+		u, err := parseBaseURL(c.rangeURL)
+		if err != nil {
+			return err
+		}
+		c.rangeClient = c.withBaseURL(u)
+	}
+
+	if c.instantURL != "" {
+		u, err := parseBaseURL(c.instantURL)
+		if err != nil {
+			return err
+		}
+		c.instantClient = c.withBaseURL(u)
+	}
+	return nil
+}
+
+// parseBaseURL parses the URL of a Prometheus server. Request URLs are resolved against it, so it must be absolute.
+// User info, ports and query parameters of the URL are kept for all requests.
+func parseBaseURL(baseUrl string) (*url.URL, error) {
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Prometheus URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Prometheus URL %q: scheme and host are required", baseUrl)
+	}
+	u.Fragment = ""
+	return u, nil
+}
+
+// Close aborts all requests that are in flight. Requests sent after Close fail with ErrClientClosed. Copies of the
+// client created with WithBaseURL share its state, so closing one of them closes all.
+func (c *Client) Close() error {
+	c.closeRoot()
+	return nil
 }
 
-func NewClient(d doer, method, baseUrl string) *Client {
-	return &Client{doer: d, method: method, baseUrl: baseUrl}
+// WithBaseURL returns a copy of the client that sends requests to another base URL. The copy shares the doer and all
+// options with the client, except for the hedge and query URLs, which belong to the original server, and the circuit
+// breaker, which is kept per base URL. An error is returned if baseUrl is not an absolute URL.
+func (c *Client) WithBaseURL(baseUrl string) (*Client, error) {
+	u, err := parseBaseURL(baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	return c.withBaseURL(u), nil
+}
+
+func (c *Client) withBaseURL(u *url.URL) *Client {
+	clone := *c
+	clone.baseURL = u
+	if c.breakers != nil {
+		clone.doer = c.breakers.forURL(u)
+	}
+	clone.hedgeURLs = nil
+	clone.rangeURL = ""
+	clone.instantURL = ""
+	clone.hedgeClients = nil
+	clone.rangeClient = nil
+	clone.instantClient = nil
+	return &clone
 }
 
 func (c *Client) QueryRange(ctx context.Context, q *models.Query) (*http.Response, error) {
-	tr := q.TimeRange()
+	if err := c.validateQuery(q); err != nil {
+		return nil, err
+	}
+
+	if c.rangeClient != nil {
+		return c.rangeClient.QueryRange(ctx, q)
+	}
+
+	if len(c.hedgeClients) > 0 {
+		return c.hedgeQueryRange(ctx, q)
+	}
+
+	ctx, span := c.startSpan(ctx, q.Expr)
+	defer span.End()
+
+	tr := c.timeRange(q)
+	if err := c.checkPoints(tr); err != nil {
+		return nil, err
+	}
+
 	qv := map[string]string{
 		"query": q.Expr,
 		"start": formatTime(tr.Start),
 		"end":   formatTime(tr.End),
-		"step":  strconv.FormatFloat(tr.Step.Seconds(), 'f', -1, 64),
+		"step":  formatStep(tr.Step),
 	}
+	addDuration(qv, "timeout", q.Timeout)
+	addDuration(qv, "lookback_delta", q.LookbackDelta)
+	addThanosParams(qv, q)
+	addStats(qv, q.Stats)
+	addExplain(qv, q.Explain)
+	addLimit(qv, q.Limit)
+	addExtraParams(qv, q.ExtraParams)
 
-	req, err := c.createQueryRequest(ctx, "api/v1/query_range", qv)
+	endpoint := c.apiEndpoint("query_range")
+	key, cacheable := "", c.cacheable(q)
+	if cacheable {
+		key, cacheable = c.cacheKey(ctx, endpoint, qv, q.QueryTags)
+	}
+	if cacheable && !noCache(ctx) {
+		if c.root.Err() != nil {
+			return nil, ErrClientClosed
+		}
+		if body, ok := c.cache.Get(key); ok {
+			return cachedResponse(body), nil
+		}
+	}
+
+	res, err := c.doQuery(ctx, q, endpoint, qv)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.doer.Do(req)
+	res, err = checkError(res)
+	if err != nil || !cacheable {
+		return res, err
+	}
+
+	return c.storeResponse(key, res)
 }
 
 func (c *Client) QueryInstant(ctx context.Context, q *models.Query) (*http.Response, error) {
+	if err := c.validateQuery(q); err != nil {
+		return nil, err
+	}
+
+	if c.instantClient != nil {
+		return c.instantClient.QueryInstant(ctx, q)
+	}
+
+	ctx, span := c.startSpan(ctx, q.Expr)
+	defer span.End()
+
 	// We do not need a time range here.
 	// Instant query evaluates at a single point in time.
 	// Using q.TimeRange is aligning the query range to step.
@@ -58,103 +473,504 @@ func (c *Client) QueryInstant(ctx context.Context, q *models.Query) (*http.Respo
 	// Instead of aligning we use time point directly.
 	// https://prometheus.io/docs/prometheus/latest/querying/api/#instant-queries
 	qv := map[string]string{"query": q.Expr, "time": formatTime(q.End)}
-	req, err := c.createQueryRequest(ctx, "api/v1/query", qv)
+	addDuration(qv, "timeout", q.Timeout)
+	addDuration(qv, "lookback_delta", q.LookbackDelta)
+	addThanosParams(qv, q)
+	addStats(qv, q.Stats)
+	addExplain(qv, q.Explain)
+	addLimit(qv, q.Limit)
+	addExtraParams(qv, q.ExtraParams)
+	res, err := c.doQuery(ctx, q, c.apiEndpoint("query"), qv)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.doer.Do(req)
+	return checkError(res)
 }
 
 func (c *Client) QueryExemplars(ctx context.Context, q *models.Query) (*http.Response, error) {
-	tr := q.TimeRange()
+	ctx, span := c.startSpan(ctx, q.Expr)
+	defer span.End()
+
+	// Exemplars are not evaluated at step intervals, so the range is used as is instead of being aligned to step.
 	qv := map[string]string{
 		"query": q.Expr,
-		"start": formatTime(tr.Start),
-		"end":   formatTime(tr.End),
+		"start": formatTime(q.Start),
+		"end":   formatTime(q.End),
+	}
+
+	return c.doQuery(ctx, q, c.apiEndpoint("query_exemplars"), qv)
+}
+
+// Series finds the series that match any of the given matchers. Zero start or end times are not sent, so the server
+// defaults apply.
+func (c *Client) Series(ctx context.Context, matchers []string, start, end time.Time) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
+	req, err := c.createMatcherRequest(ctx, c.apiEndpoint("series"), matchers, start, end, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.do(req)
+}
+
+// LabelNames returns the label names, optionally limited to the series matching any of the given matchers.
+func (c *Client) LabelNames(ctx context.Context, matchers []string, start, end time.Time) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
+	req, err := c.createMatcherRequest(ctx, c.apiEndpoint("labels"), matchers, start, end, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.do(req)
+}
+
+// LabelValues returns the values of the given label, optionally limited to the series matching any of the given
+// matchers. A positive limit is sent as the limit parameter for servers that cap the number of returned values;
+// LabelValuesResult reports whether the server truncated them.
+func (c *Client) LabelValues(ctx context.Context, label string, matchers []string, start, end time.Time, limit int) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
+	if c.escapeLabelNames {
+		label = escapeLabelName(label)
+	}
+	endpoint := c.apiEndpoint("label/" + url.PathEscape(label) + "/values")
+	req, err := c.createMatcherRequest(ctx, endpoint, matchers, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.do(req)
+}
+
+// WithLabelNameEscaping makes LabelValues send label names that are not valid in the legacy character set, e.g.
+// UTF-8 names with dots, with the values escaping of Prometheus 3, like U__my_2e_label for my.label. It should be
+// enabled for servers that support it, as names with a slash cannot be sent otherwise.
+func WithLabelNameEscaping(enabled bool) Option {
+	return func(c *Client) {
+		c.escapeLabelNames = enabled
 	}
+}
+
+// escapeLabelName escapes a label name with the values escaping of Prometheus. Legacy names are returned as they are.
+func escapeLabelName(name string) string {
+	if isLegacyName(name) {
+		return name
+	}
+
+	var b strings.Builder
+	b.WriteString("U__")
+	for i, r := range name {
+		switch {
+		case r == '_':
+			b.WriteString("__")
+		case isLegacyRune(r, i):
+			b.WriteRune(r)
+		case r == utf8.RuneError:
+			b.WriteString("_FFFD_")
+		default:
+			b.WriteString("_" + strconv.FormatInt(int64(r), 16) + "_")
+		}
+	}
+	return b.String()
+}
+
+func isLegacyName(name string) bool {
+	if name == "" {
+		return true
+	}
+	for i, r := range name {
+		if !isLegacyRune(r, i) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLegacyRune(r rune, i int) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || r == ':' || (r >= '0' && r <= '9' && i > 0)
+}
+
+// Metadata returns the type, help and unit of metrics. An empty metric returns the metadata of all metrics and a limit
+// of zero or less returns all of them. The endpoint only supports GET, so the configured method is not used.
+func (c *Client) Metadata(ctx context.Context, metric string, limit int) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
+	v := make(url.Values)
+	if metric != "" {
+		v.Set("metric", metric)
+	}
+	if limit > 0 {
+		v.Set("limit", strconv.Itoa(limit))
+	}
+
+	return c.get(ctx, c.apiEndpoint("metadata"), v)
+}
+
+// Rules returns the alerting and recording rules. typeFilter can be "alert" or "record" to return only one kind of
+// rules, an empty filter returns both.
+func (c *Client) Rules(ctx context.Context, typeFilter string) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
 
-	req, err := c.createQueryRequest(ctx, "api/v1/query_exemplars", qv)
+	v := make(url.Values)
+	if typeFilter != "" {
+		v.Set("type", typeFilter)
+	}
+
+	return c.get(ctx, c.apiEndpoint("rules"), v)
+}
+
+// FormatQuery returns the expression pretty-printed by Prometheus. It returns ErrNotSupported if the server does not
+// provide the endpoint, which was added in Prometheus 2.45.
+func (c *Client) FormatQuery(ctx context.Context, expr string) (*http.Response, error) {
+	return c.postExpr(ctx, "format_query", expr)
+}
+
+// ParseQuery returns the abstract syntax tree of the expression. It returns ErrNotSupported if the server does not
+// provide the endpoint.
+func (c *Client) ParseQuery(ctx context.Context, expr string) (*http.Response, error) {
+	return c.postExpr(ctx, "parse_query", expr)
+}
+
+// postExpr posts an expression to an endpoint of the PromQL tooling API, which older servers do not have.
+func (c *Client) postExpr(ctx context.Context, endpoint, expr string) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, expr)
+	defer span.End()
+
+	u, err := c.createUrl(c.apiEndpoint(endpoint), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.doer.Do(req)
+	req, err := createRequest(ctx, http.MethodPost, u, []byte(encodeParams(url.Values{"query": {expr}})))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotSupported, endpoint)
+	}
+
+	return checkError(res)
 }
 
 func (c *Client) QueryResource(ctx context.Context, req *backend.CallResourceRequest) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
 	// The way URL is represented in CallResourceRequest and what we need for the fetch function is different
 	// so here we have to do a bit of parsing, so we can then compose it with the base url in correct way.
 	reqUrlParsed, err := url.Parse(req.URL)
 	if err != nil {
 		return nil, err
 	}
-	u, err := c.createUrl(req.Path, nil)
+	u, err := c.createUrl(path.Join("/", c.resourcePrefix, (&url.URL{Path: req.Path}).EscapedPath()), nil)
 	if err != nil {
 		return nil, err
 	}
-	u.RawQuery = reqUrlParsed.RawQuery
+	// Parameters of the base URL are kept, the request parameters are passed on as they are.
+	if u.RawQuery == "" {
+		u.RawQuery = reqUrlParsed.RawQuery
+	} else if reqUrlParsed.RawQuery != "" {
+		u.RawQuery += "&" + reqUrlParsed.RawQuery
+	}
 
 	// We use method from the request, as for resources front end may do a fallback to GET if POST does not work
 	// nad we want to respect that.
-	httpRequest, err := createRequest(ctx, req.Method, u, bytes.NewReader(req.Body))
+	httpRequest, err := createRequest(ctx, req.Method, u, req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.rawBody {
+		return c.send(httpRequest)
+	}
+
+	return c.do(httpRequest)
+}
+
+// do sends the request and transparently decompresses the response body, so all endpoints share the same
+// response handling.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	start := c.now()
+	res, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	decode := decompress
+	if c.disableAcceptEncoding {
+		decode = sniffDecompress
+	}
+	if c.corruptGzipFallback {
+		res, err = c.decompressOrRaw(req, res, decode)
+	} else {
+		res, err = decode(res, c.maxDecompressedSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.observeResponse(req, res, start)
+	return res, nil
+}
+
+// send sends the request and returns the response as received.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", c.userAgent)
+	// net/http ignores the Host header and sends the Host field of the request instead.
+	if c.host != "" {
+		req.Host = c.host
+	}
+	c.setAuthorization(req)
+	applyContextHeaders(req)
+	c.setAcceptEncoding(req)
+	c.debugRequest(req)
+
+	if c.root.Err() != nil {
+		return nil, ErrClientClosed
+	}
+
+	// Requests are canceled when the client is closed, in addition to the context of the caller.
+	ctx, cancel := context.WithCancel(req.Context())
+	stop := context.AfterFunc(c.root, cancel)
+	release := func() {
+		stop()
+		cancel()
+	}
+	if _, ok := ctx.Deadline(); !ok && c.defaultTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, c.defaultTimeout)
+		release = func() {
+			cancelTimeout()
+			stop()
+			cancel()
+		}
+	}
+	req = req.WithContext(ctx)
+
+	start := c.now()
+	res, err := c.doer.Do(req)
+	c.recordSpan(req, res, err)
+	c.logRequest(req, res, err, c.since(start))
+	if err != nil {
+		release()
+		if c.root.Err() != nil {
+			return nil, fmt.Errorf("%w: %v", ErrClientClosed, err)
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %v", ErrRequestTimeout, err)
+		}
+		return nil, err
+	}
+
+	// The context has to stay active while the body is read, so it is only canceled once the body is closed.
+	res.Body = &cancelCloser{ReadCloser: res.Body, cancel: release}
+
+	return res, nil
+}
+
+// cancelCloser cancels the context of a request when its response body is closed.
+type cancelCloser struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// Alerts returns the active alerts.
+func (c *Client) Alerts(ctx context.Context) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
+	return c.get(ctx, c.apiEndpoint("alerts"), nil)
+}
+
+// Targets returns the scrape targets. state can be "active", "dropped" or "any", an empty state uses the server
+// default.
+func (c *Client) Targets(ctx context.Context, state string) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
+	v := make(url.Values)
+	if state != "" {
+		v.Set("state", state)
+	}
+
+	return c.get(ctx, c.apiEndpoint("targets"), v)
+}
+
+// TSDBStatus returns the cardinality statistics of the TSDB head, like the series count by metric name. limit sets the
+// number of entries of the top lists, zero or less uses the server default.
+func (c *Client) TSDBStatus(ctx context.Context, limit int) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
+	v := make(url.Values)
+	if limit > 0 {
+		v.Set("limit", strconv.Itoa(limit))
+	}
+
+	return c.get(ctx, c.apiEndpoint("status/tsdb"), v)
+}
+
+// get sends a GET request to endpoints that do not support POST, regardless of the configured method.
+func (c *Client) get(ctx context.Context, endpoint string, qs url.Values) (*http.Response, error) {
+	u, err := c.createUrl(endpoint, qs)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := createRequest(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.doer.Do(httpRequest)
+	return c.do(req)
+}
+
+// apiEndpoint returns the path of an API endpoint relative to the base URL.
+func (c *Client) apiEndpoint(endpoint string) string {
+	return path.Join(c.apiPrefix, endpoint)
+}
+
+func (c *Client) createQueryRequest(ctx context.Context, method, endpoint string, qv map[string]string) (*http.Request, error) {
+	v := make(url.Values, len(qv))
+	for key, val := range qv {
+		v.Set(key, val)
+	}
+
+	req, err := c.createValuesRequest(ctx, method, endpoint, v)
+	if err != nil {
+		return nil, err
+	}
+	if req.Method == http.MethodPost && c.postContentType != "" {
+		req.Header.Set("Content-Type", c.postContentType)
+	}
+	return req, nil
 }
 
-func (c *Client) createQueryRequest(ctx context.Context, endpoint string, qv map[string]string) (*http.Request, error) {
-	if strings.ToUpper(c.method) == http.MethodPost {
+// createValuesRequest creates a request with the given parameters encoded in the query string or, for POST, in the
+// form encoded body. An empty method uses the method of the client. Unlike createQueryRequest it supports repeated
+// parameters like match[].
+func (c *Client) createValuesRequest(ctx context.Context, method, endpoint string, v url.Values) (*http.Request, error) {
+	encoded := encodeParams(v)
+	method = c.queryMethod(method, encoded)
+	if strings.ToUpper(method) == http.MethodPost {
 		u, err := c.createUrl(endpoint, nil)
 		if err != nil {
 			return nil, err
 		}
 
-		v := make(url.Values)
-		for key, val := range qv {
-			v.Set(key, val)
+		body := []byte(encoded)
+		gzipped := c.compressThreshold > 0 && len(encoded) > c.compressThreshold
+		if gzipped {
+			if body, err = gzipBody(encoded); err != nil {
+				return nil, err
+			}
 		}
 
-		return createRequest(ctx, c.method, u, strings.NewReader(v.Encode()))
+		req, err := createRequest(ctx, method, u, body)
+		if err != nil {
+			return nil, err
+		}
+		if gzipped {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		return req, nil
 	}
 
-	u, err := c.createUrl(endpoint, qv)
+	u, err := c.createUrl(endpoint, v)
 	if err != nil {
 		return nil, err
 	}
 
-	return createRequest(ctx, c.method, u, http.NoBody)
+	return createRequest(ctx, method, u, nil)
 }
 
-func (c *Client) createUrl(endpoint string, qs map[string]string) (*url.URL, error) {
-	finalUrl, err := url.ParseRequestURI(c.baseUrl)
+// queryMethod returns the HTTP method for a query with the given encoded parameters. An empty method is replaced by
+// the configured one. With AutoMethod it depends on their length, otherwise the method is used as is.
+func (c *Client) queryMethod(method, encoded string) string {
+	if method == "" {
+		method = c.method
+	}
+	if strings.ToUpper(method) != AutoMethod {
+		return method
+	}
+	if len(encoded) > c.autoMethodThreshold {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+func (c *Client) createUrl(endpoint string, qs url.Values) (*url.URL, error) {
+	finalUrl := *c.baseURL
+
+	// The endpoint is an escaped path, so it can contain segments like label names with escaped special characters.
+	// Joining it to the path of the base URL keeps path prefixes of proxies, with or without a trailing slash.
+	finalUrl.RawPath = path.Join("/", finalUrl.EscapedPath(), endpoint)
+	var err error
+	finalUrl.Path, err = url.PathUnescape(finalUrl.RawPath)
 	if err != nil {
 		return nil, err
 	}
 
-	finalUrl.Path = path.Join(finalUrl.Path, endpoint)
-
 	// don't re-encode the Query if not needed
 	if len(qs) != 0 {
 		urlQuery := finalUrl.Query()
 
-		for key, val := range qs {
-			urlQuery.Set(key, val)
+		for key, vals := range qs {
+			urlQuery[key] = vals
 		}
 
-		finalUrl.RawQuery = urlQuery.Encode()
+		finalUrl.RawQuery = encodeParams(urlQuery)
+		if c.percentEncodedSpaces {
+			// Literal plus signs are encoded as %2B, so every plus left is a space.
+			finalUrl.RawQuery = strings.ReplaceAll(finalUrl.RawQuery, "+", "%20")
+		}
 	}
 
-	return finalUrl, nil
+	return &finalUrl, nil
+}
+
+// encodeParams encodes parameters for query strings and form bodies of all endpoints. Keys are sorted and repeated
+// values keep their order, so the encoding is the same for the same parameters.
+func encodeParams(v url.Values) string {
+	return v.Encode()
 }
 
-func createRequest(ctx context.Context, method string, u *url.URL, bodyReader io.Reader) (*http.Request, error) {
+// createRequest creates a request with a buffered body. GetBody returns a new reader of the same bytes, so the body
+// can be sent again on retries and redirects. Requests with an empty body send none.
+func createRequest(ctx context.Context, method string, u *url.URL, body []byte) (*http.Request, error) {
+	var bodyReader io.Reader = http.NoBody
+	if len(body) > 0 {
+		bodyReader = bytes.NewReader(body)
+	}
 	request, err := http.NewRequestWithContext(ctx, method, u.String(), bodyReader)
 	if err != nil {
 		return nil, err
 	}
+	if len(body) > 0 {
+		request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
 
 	if strings.ToUpper(method) == http.MethodPost {
 		// This may not be true but right now we don't have more information here and seems like we send just this type
@@ -167,6 +983,108 @@ func createRequest(ctx context.Context, method string, u *url.URL, bodyReader io
 	return request, nil
 }
 
+func gzipBody(body string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// matcherValues encodes series matchers as repeated match[] parameters together with the optional time range.
+func matcherValues(matchers []string, start, end time.Time) url.Values {
+	v := make(url.Values)
+	for _, m := range matchers {
+		v.Add("match[]", m)
+	}
+	if !start.IsZero() {
+		v.Set("start", formatTime(start))
+	}
+	if !end.IsZero() {
+		v.Set("end", formatTime(end))
+	}
+	return v
+}
+
+// timeRange returns the time range of a range query, aligned to its step.
+func (c *Client) timeRange(q *models.Query) models.TimeRange {
+	tr := q.TimeRange()
+	if c.alignToStep && tr.Step > 0 && tr.End.Before(q.End) {
+		tr.End = tr.End.Add(tr.Step)
+	}
+	return tr
+}
+
+// addDuration sets a duration parameter like timeout only when it is configured, so the server default applies
+// otherwise.
+func addDuration(qv map[string]string, key string, d time.Duration) {
+	if d > 0 {
+		qv[key] = formatDuration(d)
+	}
+}
+
+// addLimit sets the series limit of a query only when it is positive.
+func addLimit(qv map[string]string, limit int) {
+	if limit > 0 {
+		qv["limit"] = strconv.Itoa(limit)
+	}
+}
+
+// addThanosParams sets the Thanos specific parameters that are enabled on the query.
+func addThanosParams(qv map[string]string, q *models.Query) {
+	if q.PartialResponse {
+		qv["partial_response"] = "true"
+	}
+	if q.Dedup {
+		qv["dedup"] = "true"
+	}
+	addDuration(qv, "max_source_resolution", q.MaxSourceResolution)
+}
+
+// reservedParams are the parameters that define a query. Extra parameters cannot set them.
+var reservedParams = map[string]bool{"query": true, "start": true, "end": true, "step": true, "time": true}
+
+// addExtraParams adds the extra parameters of a query after the standard ones. They never replace parameters the
+// client already set, and reserved parameters are ignored even if the client did not set them.
+func addExtraParams(qv map[string]string, extra map[string]string) {
+	for key, val := range extra {
+		if _, ok := qv[key]; ok || reservedParams[key] {
+			continue
+		}
+		qv[key] = val
+	}
+}
+
+// formatDuration formats durations the way Prometheus parses them, e.g. 30s or 1m30s.
+func formatDuration(d time.Duration) string {
+	return model.Duration(d).String()
+}
+
+// formatStep formats a step as seconds. Sub-second steps keep their fraction, e.g. 0.5 or 1.5, while whole seconds
+// are sent without a decimal point.
+func formatStep(step time.Duration) string {
+	return strconv.FormatFloat(step.Seconds(), 'f', -1, 64)
+}
+
+// formatTime formats a time as plain decimal unix seconds. The fraction is formatted from the nanoseconds instead of
+// a float, so it is exact and never uses scientific notation.
 func formatTime(t time.Time) string {
-	return strconv.FormatFloat(float64(t.Unix())+float64(t.Nanosecond())/1e9, 'f', -1, 64)
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	if nsec == 0 {
+		return strconv.FormatInt(sec, 10)
+	}
+
+	sign := ""
+	if sec < 0 {
+		// Unix rounds down, so negative times have a positive fraction that has to be turned around.
+		sign = "-"
+		sec, nsec = -(sec + 1), 1e9-nsec
+	}
+
+	frac := strings.TrimRight(fmt.Sprintf("%09d", nsec), "0")
+	return sign + strconv.FormatInt(sec, 10) + "." + frac
 }
@@ -0,0 +1,147 @@
+package clienttest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Responder returns the response to a request of a FakeDoer.
+type Responder func(req *http.Request) (*http.Response, error)
+
+// FakeDoer is a doer for unit tests of the client. It records the requests and answers them with its Responder. The
+// bodies of answered requests are read like a server would, the bodies of requests the Responder fails are left
+// untouched like by a failed dial. It is safe for concurrent use.
+type FakeDoer struct {
+	respond Responder
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+// NewFakeDoer returns a FakeDoer answering requests with respond.
+func NewFakeDoer(respond Responder) *FakeDoer {
+	return &FakeDoer{respond: respond}
+}
+
+// Do records the request and returns the response of the Responder. The body of the request is replaced after reading,
+// so tests can read it again.
+func (d *FakeDoer) Do(req *http.Request) (*http.Response, error) {
+	d.mu.Lock()
+	i := len(d.requests)
+	d.requests = append(d.requests, req)
+	d.bodies = append(d.bodies, "")
+	d.mu.Unlock()
+
+	res, err := d.respond(req)
+	if err != nil || req.Body == nil || req.Body == http.NoBody {
+		return res, err
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	d.mu.Lock()
+	d.bodies[i] = string(body)
+	d.mu.Unlock()
+	return res, nil
+}
+
+// Requests returns the requests received so far.
+func (d *FakeDoer) Requests() []*http.Request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*http.Request(nil), d.requests...)
+}
+
+// LastRequest returns the last request received, or nil before the first one.
+func (d *FakeDoer) LastRequest() *http.Request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.requests) == 0 {
+		return nil
+	}
+	return d.requests[len(d.requests)-1]
+}
+
+// Bodies returns the bodies of the requests received so far, empty for requests without body or that failed.
+func (d *FakeDoer) Bodies() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.bodies...)
+}
+
+// Calls returns the number of requests received so far.
+func (d *FakeDoer) Calls() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.requests)
+}
+
+// Echo responds with status 200 and the body of the request.
+func Echo(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// Respond responds with the status, body and header. A nil header sets the Content-Type to JSON.
+func Respond(status int, body string, header http.Header) Responder {
+	if header == nil {
+		header = http.Header{"Content-Type": {"application/json"}}
+	}
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Header:     header.Clone(),
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	}
+}
+
+// Status responds with the status and an empty body.
+func Status(status int) Responder {
+	return Respond(status, "", http.Header{})
+}
+
+// Fail returns err instead of a response, like a failed connection.
+func Fail(err error) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		return nil, err
+	}
+}
+
+// Sequence answers the requests with the responders in turn, one per request. Requests after the last one fail.
+func Sequence(responders ...Responder) Responder {
+	var mu sync.Mutex
+	next := 0
+	return func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		i := next
+		next++
+		mu.Unlock()
+		if i >= len(responders) {
+			return nil, fmt.Errorf("unexpected request %d to %s", i+1, req.URL)
+		}
+		return responders[i](req)
+	}
+}
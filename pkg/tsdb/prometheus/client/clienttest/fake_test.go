@@ -0,0 +1,71 @@
+package clienttest_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+func TestFakeDoer(t *testing.T) {
+	newRequest := func(t *testing.T, body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:9090/api/v1/query", strings.NewReader(body))
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("records requests and bodies", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		require.Nil(t, doer.LastRequest())
+
+		res, err := doer.Do(newRequest(t, "query=up"))
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "query=up", string(body))
+
+		require.Equal(t, 1, doer.Calls())
+		require.Equal(t, []string{"query=up"}, doer.Bodies())
+		body, err = io.ReadAll(doer.LastRequest().Body)
+		require.NoError(t, err)
+		require.Equal(t, "query=up", string(body))
+	})
+
+	t.Run("leaves bodies of failed requests untouched", func(t *testing.T) {
+		dial := errors.New("connection refused")
+		doer := clienttest.NewFakeDoer(clienttest.Fail(dial))
+		req := newRequest(t, "query=up")
+
+		_, err := doer.Do(req)
+		require.ErrorIs(t, err, dial)
+		require.Equal(t, []string{""}, doer.Bodies())
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.Equal(t, "query=up", string(body))
+	})
+
+	t.Run("answers requests in sequence", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Sequence(
+			clienttest.Status(http.StatusServiceUnavailable),
+			clienttest.Respond(http.StatusOK, `{"status":"success"}`, nil),
+		))
+
+		res, err := doer.Do(newRequest(t, ""))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+
+		res, err = doer.Do(newRequest(t, ""))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+		_, err = doer.Do(newRequest(t, ""))
+		require.ErrorContains(t, err, "unexpected request 3")
+		require.Len(t, doer.Requests(), 3)
+	})
+}
@@ -0,0 +1,250 @@
+package clienttest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Doer sends HTTP requests like the doer passed to client.NewClient, e.g. an *http.Client.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// RecordedRequest is a request of a recording. Form encoded bodies are stored with the query string in Params, other
+// bodies, e.g. JSON matchers, in Body.
+type RecordedRequest struct {
+	Method string     `json:"method"`
+	Path   string     `json:"path"`
+	Params url.Values `json:"params,omitempty"`
+	Body   string     `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response of a recording. Gzipped bodies are stored decompressed.
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Interaction is a request and the response it received.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Recorder is a Doer that sends requests with another Doer and records them with their responses, e.g. to capture
+// traffic against a staging Prometheus for replaying it with a Replayer in tests.
+type Recorder struct {
+	next Doer
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder returns a Recorder that sends requests with next.
+func NewRecorder(next Doer) *Recorder {
+	return &Recorder{next: next}
+}
+
+// Do sends the request and records it together with its response. Requests that fail without a response are not
+// recorded.
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := readResponseBody(res)
+	if err != nil {
+		return nil, err
+	}
+	header := res.Header.Clone()
+	header.Del("Set-Cookie")
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request:  recorded,
+		Response: RecordedResponse{Status: res.StatusCode, Header: header, Body: string(body)},
+	})
+	r.mu.Unlock()
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	return res, nil
+}
+
+// Interactions returns the interactions recorded so far.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Save writes the interactions recorded so far to a JSON file that can be loaded with LoadReplayer.
+func (r *Recorder) Save(path string) error {
+	b, err := json.MarshalIndent(r.Interactions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// Replayer is a Doer that responds to requests with the responses of a recording instead of sending them. Requests
+// match an interaction with the same method, path, parameters and body. Every interaction is used once, in the order
+// of the recording, so repeated requests receive the responses they received when recorded.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer returns a Replayer for the given interactions.
+func NewReplayer(interactions []Interaction) *Replayer {
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions))}
+}
+
+// LoadReplayer returns a Replayer for the interactions of a file written by Recorder.Save.
+func LoadReplayer(path string) (*Replayer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(b, &interactions); err != nil {
+		return nil, fmt.Errorf("invalid recording %s: %w", path, err)
+	}
+	return NewReplayer(interactions), nil
+}
+
+// Do returns the recorded response of the first unused interaction that matches the request, or an error if there is
+// none.
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if r.used[i] || !matches(in.Request, recorded) {
+			continue
+		}
+		r.used[i] = true
+
+		header := in.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			StatusCode:    in.Response.Status,
+			Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL.String())
+}
+
+// Unused returns the requests of the interactions that have not been replayed, so tests can check that all
+// recorded requests were sent.
+func (r *Replayer) Unused() []RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []RecordedRequest
+	for i, in := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, in.Request)
+		}
+	}
+	return unused
+}
+
+func matches(a, b RecordedRequest) bool {
+	return a.Method == b.Method && a.Path == b.Path && a.Params.Encode() == b.Params.Encode() && a.Body == b.Body
+}
+
+// recordRequest returns the recorded form of a request. The body is read and restored, so the request can still be
+// sent.
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	recorded := RecordedRequest{Method: req.Method, Path: req.URL.Path, Params: req.URL.Query()}
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+
+	b, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return RecordedRequest{}, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+
+	body := b
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		if body, err = gunzip(b); err != nil {
+			return RecordedRequest{}, fmt.Errorf("failed to read gzipped request body: %w", err)
+		}
+	}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return RecordedRequest{}, err
+		}
+		for k, v := range form {
+			recorded.Params[k] = append(recorded.Params[k], v...)
+		}
+		return recorded, nil
+	}
+	recorded.Body = string(body)
+	return recorded, nil
+}
+
+// readResponseBody reads and closes the body of a response, decompressing gzipped bodies and dropping the headers
+// that no longer apply to them.
+func readResponseBody(res *http.Response) ([]byte, error) {
+	if res.Body == nil {
+		return nil, nil
+	}
+	b, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		return b, nil
+	}
+
+	if b, err = gunzip(b); err != nil {
+		return nil, fmt.Errorf("failed to read gzipped response body: %w", err)
+	}
+	res.Header = res.Header.Clone()
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	return b, nil
+}
+
+func gunzip(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	return io.ReadAll(r)
+}
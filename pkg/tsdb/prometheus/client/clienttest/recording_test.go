@@ -0,0 +1,115 @@
+package clienttest_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	query := &models.Query{Expr: "up", Start: time.Unix(1641889530, 0), End: time.Unix(1641889545, 0), Step: 15 * time.Second}
+
+	record := func(t *testing.T, method string) string {
+		srv := clienttest.NewServer(t)
+		recorder := clienttest.NewRecorder(http.DefaultClient)
+		c, err := client.NewClient(recorder, method, srv.URL)
+		require.NoError(t, err)
+
+		_, err = c.QueryRangeFrames(context.Background(), query)
+		require.NoError(t, err)
+		res, err := c.Series(context.Background(), []string{"up"}, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Len(t, recorder.Interactions(), 2)
+
+		path := filepath.Join(t.TempDir(), "recording.json")
+		require.NoError(t, recorder.Save(path))
+		return path
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		t.Run("replays recorded "+method+" requests", func(t *testing.T) {
+			replayer, err := clienttest.LoadReplayer(record(t, method))
+			require.NoError(t, err)
+			c, err := client.NewClient(replayer, method, "http://localhost:9090")
+			require.NoError(t, err)
+
+			frames, err := c.QueryRangeFrames(context.Background(), query)
+			require.NoError(t, err)
+			require.Len(t, frames, 2)
+			res, err := c.Series(context.Background(), []string{"up"}, time.Time{}, time.Time{})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.NoError(t, res.Body.Close())
+			require.Empty(t, replayer.Unused())
+		})
+	}
+
+	t.Run("records gzipped responses decompressed", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			_, _ = io.WriteString(gz, clienttest.VectorResponse)
+			_ = gz.Close()
+		}))
+		t.Cleanup(srv.Close)
+		recorder := clienttest.NewRecorder(http.DefaultClient)
+		c, err := client.NewClient(recorder, http.MethodGet, srv.URL)
+		require.NoError(t, err)
+
+		frames, err := c.QueryInstantFrames(context.Background(), query)
+		require.NoError(t, err)
+		require.Len(t, frames, 2)
+
+		interactions := recorder.Interactions()
+		require.Len(t, interactions, 1)
+		require.Equal(t, clienttest.VectorResponse, interactions[0].Response.Body)
+		require.Empty(t, interactions[0].Response.Header.Get("Content-Encoding"))
+	})
+
+	t.Run("fails requests that were not recorded", func(t *testing.T) {
+		replayer, err := clienttest.LoadReplayer(record(t, http.MethodGet))
+		require.NoError(t, err)
+		c, err := client.NewClient(replayer, http.MethodGet, "http://localhost:9090")
+		require.NoError(t, err)
+
+		other := *query
+		other.Expr = "down"
+		_, err = c.QueryRange(context.Background(), &other)
+		require.ErrorContains(t, err, "no recorded response")
+		require.Len(t, replayer.Unused(), 2)
+	})
+
+	t.Run("uses every interaction once", func(t *testing.T) {
+		req := clienttest.RecordedRequest{Method: http.MethodGet, Path: "/api/v1/series"}
+		replayer := clienttest.NewReplayer([]clienttest.Interaction{
+			{Request: req, Response: clienttest.RecordedResponse{Status: http.StatusServiceUnavailable}},
+			{Request: req, Response: clienttest.RecordedResponse{Status: http.StatusOK, Body: clienttest.SeriesResponse}},
+		})
+
+		for _, status := range []int{http.StatusServiceUnavailable, http.StatusOK} {
+			r, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/series", nil)
+			require.NoError(t, err)
+			res, err := replayer.Do(r)
+			require.NoError(t, err)
+			require.Equal(t, status, res.StatusCode)
+			require.NoError(t, res.Body.Close())
+		}
+		r, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/series", nil)
+		require.NoError(t, err)
+		_, err = replayer.Do(r)
+		require.Error(t, err)
+	})
+}
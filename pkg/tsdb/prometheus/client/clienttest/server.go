@@ -0,0 +1,172 @@
+// Package clienttest provides a fake Prometheus server and doers that record and replay traffic for testing code that
+// uses the Prometheus client.
+package clienttest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sync"
+	"testing"
+)
+
+// Endpoints served by default, named after the last segment of their path.
+const (
+	QueryRange = "query_range"
+	Query      = "query"
+	Series     = "series"
+)
+
+// MatrixResponse is the default response of range queries.
+const MatrixResponse = `{"status":"success","data":{"resultType":"matrix","result":[` +
+	`{"metric":{"__name__":"up","job":"prometheus","instance":"localhost:9090"},"values":[[1641889530,"1"],[1641889545,"1"]]},` +
+	`{"metric":{"__name__":"up","job":"node","instance":"localhost:9100"},"values":[[1641889530,"0"],[1641889545,"1"]]}]}}`
+
+// VectorResponse is the default response of instant queries.
+const VectorResponse = `{"status":"success","data":{"resultType":"vector","result":[` +
+	`{"metric":{"__name__":"up","job":"prometheus","instance":"localhost:9090"},"value":[1641889545,"1"]},` +
+	`{"metric":{"__name__":"up","job":"node","instance":"localhost:9100"},"value":[1641889545,"1"]}]}}`
+
+// SeriesResponse is the default response of series requests.
+const SeriesResponse = `{"status":"success","data":[` +
+	`{"__name__":"up","job":"prometheus","instance":"localhost:9090"},` +
+	`{"__name__":"up","job":"node","instance":"localhost:9100"}]}`
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	// Params are the parameters of the query string and the form encoded body.
+	Params url.Values
+	Header http.Header
+}
+
+type response struct {
+	status   int
+	body     string
+	warnings []string
+}
+
+// Server is a fake Prometheus server. It responds to range queries, instant queries and series requests with canned
+// responses, which can be replaced per endpoint, and records all requests. Other endpoints respond with 404.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]response
+	requests  []Request
+}
+
+// NewServer starts a server that is closed when the test finishes. Its URL is the base URL for the client.
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		responses: map[string]response{
+			QueryRange: {status: http.StatusOK, body: MatrixResponse},
+			Query:      {status: http.StatusOK, body: VectorResponse},
+			Series:     {status: http.StatusOK, body: SeriesResponse},
+		},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// SetResponse replaces the response of an endpoint, e.g. QueryRange. The body is sent as JSON.
+func (s *Server) SetResponse(endpoint string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[endpoint] = response{status: status, body: body}
+}
+
+// SetError makes an endpoint respond with a Prometheus error, e.g. status 400 with errorType bad_data.
+func (s *Server) SetError(endpoint string, status int, errorType, message string) {
+	body, _ := json.Marshal(map[string]string{"status": "error", "errorType": errorType, "error": message})
+	s.SetResponse(endpoint, status, string(body))
+}
+
+// SetWarnings adds warnings to the current response of an endpoint.
+func (s *Server) SetWarnings(endpoint string, warnings ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.responses[endpoint]
+	r.warnings = warnings
+	s.responses[endpoint] = r
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the last request received by an endpoint.
+func (s *Server) LastRequest(endpoint string) (Request, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if path.Base(s.requests[i].Path) == endpoint {
+			return s.requests[i], true
+		}
+	}
+	return Request{}, false
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	params, err := readParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Params: params, Header: r.Header.Clone()})
+	res, ok := s.responses[path.Base(r.URL.Path)]
+	s.mu.Unlock()
+
+	if !ok {
+		res = response{status: http.StatusNotFound, body: `{"status":"error","errorType":"not_found","error":"endpoint not found"}`}
+	}
+
+	body := res.body
+	if len(res.warnings) > 0 {
+		if body, err = addWarnings(body, res.warnings); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(res.status)
+	_, _ = io.WriteString(w, body)
+}
+
+// readParams returns the parameters of the query string and, for POST, of the possibly gzipped form body.
+func readParams(r *http.Request) (url.Values, error) {
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		body, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzipped body: %w", err)
+		}
+		r.Body = body
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	return r.Form, nil
+}
+
+func addWarnings(body string, warnings []string) (string, error) {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		return "", fmt.Errorf("failed to add warnings to response: %w", err)
+	}
+	m["warnings"] = warnings
+	b, err := json.Marshal(m)
+	return string(b), err
+}
@@ -0,0 +1,84 @@
+package clienttest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestServer(t *testing.T) {
+	query := &models.Query{Expr: "up", Start: time.Unix(1641889530, 0), End: time.Unix(1641889545, 0), Step: 15 * time.Second}
+
+	newClient := func(t *testing.T, srv *clienttest.Server, method string) *client.Client {
+		c, err := client.NewClient(nil, method, srv.URL)
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("serves canned range query", func(t *testing.T) {
+		srv := clienttest.NewServer(t)
+
+		frames, err := newClient(t, srv, http.MethodPost).QueryRangeFrames(context.Background(), query)
+		require.NoError(t, err)
+		require.Len(t, frames, 2)
+
+		req, ok := srv.LastRequest(clienttest.QueryRange)
+		require.True(t, ok)
+		require.Equal(t, http.MethodPost, req.Method)
+		require.Equal(t, "up", req.Params.Get("query"))
+		require.Equal(t, "1641889530", req.Params.Get("start"))
+		require.Equal(t, "15", req.Params.Get("step"))
+	})
+
+	t.Run("records GET parameters", func(t *testing.T) {
+		srv := clienttest.NewServer(t)
+		c := newClient(t, srv, http.MethodGet)
+
+		res, err := c.Series(context.Background(), []string{"up", "down"}, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		res, err = c.QueryInstant(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		req, ok := srv.LastRequest(clienttest.Series)
+		require.True(t, ok)
+		require.Equal(t, []string{"up", "down"}, req.Params["match[]"])
+		require.Len(t, srv.Requests(), 2)
+	})
+
+	t.Run("injects errors", func(t *testing.T) {
+		srv := clienttest.NewServer(t)
+		srv.SetError(clienttest.QueryRange, http.StatusUnprocessableEntity, "execution", "query timed out")
+
+		_, err := newClient(t, srv, http.MethodGet).QueryRangeFrames(context.Background(), query)
+		var promErr *client.PrometheusError
+		require.ErrorAs(t, err, &promErr)
+		require.Equal(t, "query timed out", promErr.Message)
+	})
+
+	t.Run("injects warnings", func(t *testing.T) {
+		srv := clienttest.NewServer(t)
+		srv.SetWarnings(clienttest.QueryRange, "results may be incomplete")
+
+		result, err := newClient(t, srv, http.MethodGet).QueryRangeResult(context.Background(), query)
+		require.NoError(t, err)
+		require.Equal(t, []string{"results may be incomplete"}, result.Warnings)
+	})
+
+	t.Run("responds with 404 to other endpoints", func(t *testing.T) {
+		srv := clienttest.NewServer(t)
+
+		res, err := newClient(t, srv, http.MethodGet).Rules(context.Background(), "")
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"time"
+)
+
+// WithClock sets the function the client reads the current time from, instead of time.Now. It is used to skip
+// caching of queries up to now, for the circuit breaker cooldown, for Retry-After dates and for request durations, so
+// tests of them do not depend on the wall clock. Timers, like the delay between retries, and context deadlines are not
+// affected. The cache set with WithCache keeps its own clock, see WithLRUClock. A nil function keeps time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		if now != nil {
+			c.now = now
+		}
+	}
+}
+
+// since returns the time elapsed since t according to the clock of the client.
+func (c *Client) since(t time.Time) time.Duration {
+	return c.now().Sub(t)
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+// fakeClock returns a fixed time that only changes when advanced.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func TestClock(t *testing.T) {
+	query := &models.Query{Expr: "up", Start: time.Unix(0, 0), End: time.Unix(3600, 0), Step: time.Second, RangeQuery: true}
+
+	t.Run("skips caching of queries up to the time of the clock", func(t *testing.T) {
+		clock := &fakeClock{t: query.End.Add(30 * time.Second)}
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithCache(NewLRUCache(10), time.Minute), WithClock(clock.now))
+
+		for i := 0; i < 2; i++ {
+			_, err := client.QueryRangeFrames(context.Background(), query)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 2, doer.Calls())
+
+		clock.t = query.End.Add(time.Hour)
+		for i := 0; i < 2; i++ {
+			_, err := client.QueryRangeFrames(context.Background(), query)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 3, doer.Calls())
+	})
+
+	t.Run("measures request durations with the clock", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			clock.t = clock.t.Add(3 * time.Second)
+			return clienttest.Echo(req)
+		})
+		var logs []RequestLog
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithClock(clock.now), WithRequestLogger(func(l RequestLog) {
+			logs = append(logs, l)
+		}))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Len(t, logs, 1)
+		require.Equal(t, 3*time.Second, logs[0].Duration)
+	})
+
+	t.Run("uses the clock of the cache for expiry", func(t *testing.T) {
+		clock := &fakeClock{t: query.End.Add(time.Hour)}
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil))
+		cache := NewLRUCache(10, WithLRUClock(clock.now))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithCache(cache, time.Minute), WithClock(clock.now))
+
+		for i := 0; i < 2; i++ {
+			_, err := client.QueryRangeFrames(context.Background(), query)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 1, doer.Calls())
+
+		clock.t = clock.t.Add(2 * time.Minute)
+		_, err := client.QueryRangeFrames(context.Background(), query)
+		require.NoError(t, err)
+		require.Equal(t, 2, doer.Calls())
+	})
+
+	t.Run("does not change the clock of the cache", func(t *testing.T) {
+		clock := &fakeClock{t: query.End.Add(time.Hour)}
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithCache(NewLRUCache(10), time.Minute), WithClock(clock.now))
+
+		_, err := client.QueryRangeFrames(context.Background(), query)
+		require.NoError(t, err)
+		clock.t = clock.t.Add(2 * time.Minute)
+		_, err = client.QueryRangeFrames(context.Background(), query)
+		require.NoError(t, err)
+		require.Equal(t, 1, doer.Calls())
+	})
+
+	t.Run("uses the clock for Retry-After dates", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(1641889530, 0)}
+		retryAt := clock.t.Add(time.Minute).UTC().Format(http.TimeFormat)
+		next := clienttest.NewFakeDoer(clienttest.Sequence(
+			func(req *http.Request) (*http.Response, error) {
+				res, err := clienttest.Status(http.StatusServiceUnavailable)(req)
+				res.Header.Set("Retry-After", retryAt)
+				return res, err
+			},
+			clienttest.Status(http.StatusOK),
+		))
+		policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second}
+		client := newTestClient(t, next, http.MethodGet, "http://localhost:9090", WithRetryPolicy(policy), WithClock(clock.now))
+
+		// By the wall clock the date has passed, by the client clock it is a minute above the maximum delay.
+		res, err := client.Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		require.Equal(t, 1, next.Calls())
+	})
+
+	t.Run("keeps the wall clock for context deadlines", func(t *testing.T) {
+		// By the client clock the deadline has passed long ago, by the wall clock there is time for a retry.
+		clock := &fakeClock{t: time.Now().Add(time.Hour)}
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable), clienttest.Status(http.StatusOK)))
+		policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+		client := newTestClient(t, next, http.MethodGet, "http://localhost:9090", WithRetryPolicy(policy), WithClock(clock.now))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		res, err := client.Alerts(ctx)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, 2, next.Calls())
+	})
+
+	t.Run("uses the clock for the circuit breaker cooldown", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		calls := 0
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return nil, errors.New("connection refused")
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithClock(clock.now),
+			WithCircuitBreaker(CircuitBreakerPolicy{FailureThreshold: 1, Cooldown: time.Minute}))
+
+		_, err := client.QueryRange(context.Background(), query)
+		require.NotErrorIs(t, err, ErrCircuitOpen)
+		_, err = client.QueryRange(context.Background(), query)
+		require.ErrorIs(t, err, ErrCircuitOpen)
+
+		clock.t = clock.t.Add(time.Minute)
+		_, err = client.QueryRange(context.Background(), query)
+		require.NotErrorIs(t, err, ErrCircuitOpen)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("keeps time.Now without a clock", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Echo), http.MethodGet, "http://localhost:9090", WithClock(nil))
+		require.WithinDuration(t, time.Now(), client.now(), time.Minute)
+	})
+}
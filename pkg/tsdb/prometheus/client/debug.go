@@ -0,0 +1,71 @@
+package client
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// RequestInfo describes a request as it was sent to Prometheus.
+type RequestInfo struct {
+	Method string
+	// URL is the resolved request URL, including the query string of GET requests.
+	URL string
+	// Params are the query parameters, read from the form body of POST requests, so expanded variables like
+	// $__rate_interval can be checked regardless of the method.
+	Params url.Values
+}
+
+// WithDebugHook sets a function that is called with every request right before it is sent. It is meant for
+// troubleshooting and bug reports, and is called once per client call, not once per retry attempt.
+func WithDebugHook(hook func(RequestInfo)) Option {
+	return func(c *Client) {
+		c.debugHook = hook
+	}
+}
+
+func (c *Client) debugRequest(req *http.Request) {
+	if c.debugHook == nil {
+		return
+	}
+
+	info := RequestInfo{Method: req.Method, URL: req.URL.String(), Params: req.URL.Query()}
+	if mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && mediaType == "application/x-www-form-urlencoded" {
+		if params, err := formParams(req); err == nil {
+			info.Params = params
+		}
+	}
+	c.debugHook(info)
+}
+
+// formParams parses the form body of a request without consuming req.Body.
+func formParams(req *http.Request) (url.Values, error) {
+	if req.GetBody == nil {
+		return url.Values{}, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	var r io.Reader = body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		r = gr
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(b))
+}
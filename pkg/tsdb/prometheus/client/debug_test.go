@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestDebugHook(t *testing.T) {
+	query := &models.Query{
+		Expr:       "rate(up[1m])",
+		Start:      time.Unix(0, 0),
+		End:        time.Unix(1234, 0),
+		RangeQuery: true,
+		Step:       1 * time.Second,
+	}
+
+	t.Run("reports GET query", func(t *testing.T) {
+		var info RequestInfo
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Echo), http.MethodGet, "http://localhost:9090", WithDebugHook(func(i RequestInfo) {
+			info = i
+		}))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.MethodGet, info.Method)
+		require.Equal(t, "http://localhost:9090/api/v1/query_range?end=1234&query=rate%28up%5B1m%5D%29&start=0&step=1", info.URL)
+		require.Equal(t, "rate(up[1m])", info.Params.Get("query"))
+	})
+
+	t.Run("reports POST params from body", func(t *testing.T) {
+		var info RequestInfo
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090", WithDebugHook(func(i RequestInfo) {
+			info = i
+		}))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, RequestInfo{
+			Method: http.MethodPost,
+			URL:    "http://localhost:9090/api/v1/query_range",
+			Params: url.Values{"query": {"rate(up[1m])"}, "start": {"0"}, "end": {"1234"}, "step": {"1"}},
+		}, info)
+	})
+
+	t.Run("reports params of compressed body", func(t *testing.T) {
+		var info RequestInfo
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Echo), http.MethodPost, "http://localhost:9090", WithRequestCompression(1), WithDebugHook(func(i RequestInfo) {
+			info = i
+		}))
+
+		q := *query
+		q.Expr = strings.Repeat("a", 100)
+		res, err := client.QueryRange(context.Background(), &q)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, q.Expr, info.Params.Get("query"))
+	})
+}
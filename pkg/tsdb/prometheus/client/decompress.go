@@ -0,0 +1,307 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+const defaultMaxDecompressedSize = 100 << 20
+
+// maxZstdWindow is the largest zstd window the client decodes. The decoder allocates the window up front, so frames
+// are limited to the 8MB RFC 8878 recommends decoders to support instead of the default of the library.
+const maxZstdWindow = 8 << 20
+
+// ErrDecompressedSizeExceeded is returned when reading a compressed response body that decompresses to more than the
+// limit set with WithMaxDecompressedSize.
+var ErrDecompressedSizeExceeded = errors.New("decompressed response body exceeds size limit")
+
+// WithAcceptEncoding controls whether requests ask for compressed responses with Accept-Encoding: gzip,
+// which the client decodes. It is enabled by default. When disabled, requests send Accept-Encoding: identity and
+// responses are only un-gzipped if their body starts with the gzip magic number. An Accept-Encoding header set on the
+// request or in the context is kept either way.
+func WithAcceptEncoding(enabled bool) Option {
+	return func(c *Client) {
+		c.disableAcceptEncoding = !enabled
+	}
+}
+
+// WithZstd makes requests ask for zstd compressed responses in addition to gzip, for proxies and stores that serve
+// them. Responses with Content-Encoding: zstd are decoded either way.
+func WithZstd(enabled bool) Option {
+	return func(c *Client) {
+		c.zstd = enabled
+	}
+}
+
+// WithMaxDecompressedSize limits the size of decompressed response bodies to protect against decompression bombs.
+// Reading beyond the limit fails with ErrDecompressedSizeExceeded. A limit of zero or less uses the default of 100MB.
+func WithMaxDecompressedSize(limit int64) Option {
+	return func(c *Client) {
+		if limit <= 0 {
+			limit = defaultMaxDecompressedSize
+		}
+		c.maxDecompressedSize = limit
+	}
+}
+
+// WithCorruptGzipFallback returns the response body as received, with a logged warning, if it cannot be decompressed
+// because the compressed data is corrupt or truncated, e.g. by a misconfigured proxy. Bodies that were compressed
+// twice are decompressed twice. To detect errors before the response is returned, compressed bodies are read into
+// memory instead of being decompressed while the caller reads them.
+func WithCorruptGzipFallback(enabled bool) Option {
+	return func(c *Client) {
+		c.corruptGzipFallback = enabled
+	}
+}
+
+// setAcceptEncoding asks for compressed responses unless disabled with WithAcceptEncoding. Setting the header also
+// keeps the HTTP transport from adding its own and decoding the response before decompress sees it.
+func (c *Client) setAcceptEncoding(req *http.Request) {
+	if req.Header.Get("Accept-Encoding") != "" {
+		return
+	}
+	if c.disableAcceptEncoding {
+		req.Header.Set("Accept-Encoding", "identity")
+		return
+	}
+	if c.zstd {
+		req.Header.Set("Accept-Encoding", "zstd, gzip")
+		return
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+}
+
+// decompress replaces the response body with a decompressing reader based on the Content-Encoding header. gzip,
+// deflate, brotli and zstd are supported, other encodings are passed through untouched. Responses without the header are
+// un-gzipped if the body contains gzip compressed data. The Content-Encoding header is removed after decoding so
+// callers do not try to decompress the body a second time. Decompressed bodies larger than maxSize fail to read, zero
+// means no limit.
+func decompress(res *http.Response, maxSize int64) (*http.Response, error) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return res, nil
+	}
+
+	var reader io.Reader
+	var err error
+	switch encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))); encoding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(res.Body)
+	case "deflate":
+		reader, err = newDeflateReader(res.Body)
+	case "br":
+		reader = brotli.NewReader(res.Body)
+	case "zstd":
+		// A single decoder goroutine streams the body like the other decoders, with a bounded window.
+		var d *zstd.Decoder
+		if d, err = zstd.NewReader(res.Body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxZstdWindow)); err == nil {
+			reader = d
+			res.Body = zstdBody{ReadCloser: res.Body, decoder: d}
+		}
+	case "":
+		var compressed bool
+		reader, compressed, err = sniffGzip(res.Body)
+		if err == nil && !compressed {
+			// The peeked bytes are buffered in the reader, so the body has to be read through it.
+			res.Body = readCloser{Reader: reader, Closer: res.Body}
+			return res, nil
+		}
+	default:
+		// We do not know how to decode the body, so we leave it to the caller.
+		return res, nil
+	}
+
+	return decoded(res, reader, maxSize, err)
+}
+
+// sniffDecompress un-gzips the response body if it contains gzip compressed data, regardless of the Content-Encoding
+// header. It is used when the client does not ask for compressed responses, for servers that compress them anyway.
+func sniffDecompress(res *http.Response, maxSize int64) (*http.Response, error) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return res, nil
+	}
+
+	reader, compressed, err := sniffGzip(res.Body)
+	if err == nil && !compressed {
+		res.Body = readCloser{Reader: reader, Closer: res.Body}
+		return res, nil
+	}
+	return decoded(res, reader, maxSize, err)
+}
+
+// decompressOrRaw decodes the whole response body with decode. If the compressed data is corrupt, the body is returned
+// as received instead of failing.
+func (c *Client) decompressOrRaw(req *http.Request, res *http.Response, decode func(*http.Response, int64) (*http.Response, error)) (*http.Response, error) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return decode(res, c.maxDecompressedSize)
+	}
+
+	raw, err := readAllLimited(res.Body, c.maxDecompressedSize)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	header := res.Header.Clone()
+	res.Body = io.NopCloser(bytes.NewReader(raw))
+	body, err := decodeAll(res, decode, c.maxDecompressedSize)
+	if err == nil && res.Uncompressed && bytes.HasPrefix(body, gzipMagic) {
+		// Some proxies compress responses that are already compressed.
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		body, err = decodeAll(res, sniffDecompress, c.maxDecompressedSize)
+	}
+	if err == nil {
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		return res, nil
+	}
+	if !isCorruptCompression(err) {
+		return nil, err
+	}
+
+	c.logger.FromContext(req.Context()).Warn("Failed to decompress response body, returning it as received", "endpoint", req.URL.Path, "error", err)
+	res.Header = header
+	res.Body = io.NopCloser(bytes.NewReader(raw))
+	res.ContentLength = int64(len(raw))
+	res.Uncompressed = false
+	return res, nil
+}
+
+// decodeAll decodes the response body with decode and reads it.
+func decodeAll(res *http.Response, decode func(*http.Response, int64) (*http.Response, error), maxSize int64) ([]byte, error) {
+	decodedRes, err := decode(res, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = decodedRes.Body.Close()
+	}()
+	return io.ReadAll(decodedRes.Body)
+}
+
+// readAllLimited reads r like io.ReadAll but fails with ErrDecompressedSizeExceeded after more than limit bytes. Zero
+// means no limit.
+func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit > 0 {
+		r = &sizeLimitReader{r: r, remaining: limit, limit: limit}
+	}
+	return io.ReadAll(r)
+}
+
+// isCorruptCompression returns whether err was caused by invalid gzip, deflate or zstd data.
+func isCorruptCompression(err error) bool {
+	var corrupt flate.CorruptInputError
+	return errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &corrupt) ||
+		errors.Is(err, zlib.ErrHeader) || errors.Is(err, zlib.ErrChecksum) ||
+		errors.Is(err, zstd.ErrMagicMismatch) || errors.Is(err, zstd.ErrCRCMismatch)
+}
+
+// decoded replaces the response body with the decoding reader, or closes the body if creating the reader failed.
+func decoded(res *http.Response, reader io.Reader, maxSize int64, err error) (*http.Response, error) {
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+
+	if maxSize > 0 {
+		reader = &sizeLimitReader{r: reader, remaining: maxSize, limit: maxSize}
+	}
+
+	res.Body = readCloser{Reader: reader, Closer: res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return res, nil
+}
+
+// newDeflateReader returns a reader of a deflate encoded body. In HTTP that is zlib wrapped DEFLATE data (RFC 9110
+// section 8.4.1.2), but some servers send raw DEFLATE data, which is read without the zlib header.
+func newDeflateReader(body io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(body)
+	header, err := br.Peek(2)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !isZlibHeader(header) {
+		return flate.NewReader(br), nil
+	}
+	return zlib.NewReader(br)
+}
+
+// isZlibHeader reports whether b starts with a zlib header: the DEFLATE compression method and a check value that
+// makes the first two bytes a multiple of 31.
+func isZlibHeader(b []byte) bool {
+	return len(b) >= 2 && b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+// sniffGzip returns a gzip reader if the body starts with the gzip magic number. Otherwise, it returns a reader of the
+// unmodified body.
+func sniffGzip(body io.Reader) (io.Reader, bool, error) {
+	br := bufio.NewReader(body)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, err
+	}
+
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, false, nil
+	}
+
+	gr, err := gzip.NewReader(br)
+	return gr, true, err
+}
+
+// readCloser reads from a wrapped reader but closes the original response body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// zstdBody closes the zstd decoder together with the response body, so the resources of the decoder are released.
+type zstdBody struct {
+	io.ReadCloser
+	decoder *zstd.Decoder
+}
+
+func (b zstdBody) Close() error {
+	b.decoder.Close()
+	return b.ReadCloser.Close()
+}
+
+// sizeLimitReader is like io.LimitReader but fails instead of returning io.EOF once more than limit bytes are read,
+// so truncated bodies are not mistaken for complete ones.
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, fmt.Errorf("%w of %d bytes", ErrDecompressedSizeExceeded, l.limit)
+	}
+
+	// Read one byte more than allowed to detect bodies that exceed the limit.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), fmt.Errorf("%w of %d bytes", ErrDecompressedSizeExceeded, l.limit)
+	}
+	return n, err
+}
@@ -0,0 +1,301 @@
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+var rawBody = []byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	case "deflate":
+		w = zlib.NewWriter(buf)
+	case "raw deflate":
+		fw, err := flate.NewWriter(buf, flate.DefaultCompression)
+		require.NoError(t, err)
+		w = fw
+	case "br":
+		w = brotli.NewWriter(buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(buf)
+		require.NoError(t, err)
+		w = zw
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func responseWithBody(encoding string, body []byte) *http.Response {
+	header := http.Header{}
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+func readBody(t *testing.T, res *http.Response) []byte {
+	t.Helper()
+	defer func() {
+		require.NoError(t, res.Body.Close())
+	}()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return body
+}
+
+func TestDecompress(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		t.Run("decodes "+encoding+" based on Content-Encoding", func(t *testing.T) {
+			res, err := decompress(responseWithBody(encoding, compress(t, encoding, rawBody)), 0)
+			require.NoError(t, err)
+			require.Equal(t, rawBody, readBody(t, res))
+			require.Empty(t, res.Header.Get("Content-Encoding"))
+		})
+	}
+
+	t.Run("decodes raw deflate without zlib header", func(t *testing.T) {
+		res, err := decompress(responseWithBody("deflate", compress(t, "raw deflate", rawBody)), 0)
+		require.NoError(t, err)
+		require.Equal(t, rawBody, readBody(t, res))
+	})
+
+	t.Run("passes unknown encodings through", func(t *testing.T) {
+		res, err := decompress(responseWithBody("compress", []byte("not decoded")), 0)
+		require.NoError(t, err)
+		require.Equal(t, []byte("not decoded"), readBody(t, res))
+		require.Equal(t, "compress", res.Header.Get("Content-Encoding"))
+	})
+
+	t.Run("un-gzips body without Content-Encoding", func(t *testing.T) {
+		res, err := decompress(responseWithBody("", compress(t, "gzip", rawBody)), 0)
+		require.NoError(t, err)
+		require.Equal(t, rawBody, readBody(t, res))
+	})
+
+	t.Run("passes uncompressed body through", func(t *testing.T) {
+		res, err := decompress(responseWithBody("", rawBody), 0)
+		require.NoError(t, err)
+		require.Equal(t, rawBody, readBody(t, res))
+	})
+
+	t.Run("passes empty body through", func(t *testing.T) {
+		res, err := decompress(responseWithBody("", []byte{}), 0)
+		require.NoError(t, err)
+		require.Equal(t, []byte{}, readBody(t, res))
+	})
+
+	t.Run("fails when decompressed body exceeds limit", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 1<<16)
+		res, err := decompress(responseWithBody("gzip", compress(t, "gzip", data)), 1024)
+		require.NoError(t, err)
+		b, err := io.ReadAll(res.Body)
+		require.ErrorIs(t, err, ErrDecompressedSizeExceeded)
+		require.ErrorContains(t, err, "of 1024 bytes")
+		require.Len(t, b, 1024)
+	})
+
+	t.Run("fails when decompressed zstd body exceeds limit", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 1<<16)
+		res, err := decompress(responseWithBody("zstd", compress(t, "zstd", data)), 1024)
+		require.NoError(t, err)
+		_, err = io.ReadAll(res.Body)
+		require.ErrorIs(t, err, ErrDecompressedSizeExceeded)
+		require.NoError(t, res.Body.Close())
+	})
+
+	t.Run("fails for zstd windows above the limit", func(t *testing.T) {
+		// A frame declaring a 32MB window, followed by a single raw block with the body.
+		frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x78}
+		header := len(rawBody)<<3 | 1
+		frame = append(frame, byte(header), byte(header>>8), byte(header>>16))
+		frame = append(frame, rawBody...)
+
+		res, err := decompress(responseWithBody("zstd", frame), 0)
+		require.NoError(t, err)
+		_, err = io.ReadAll(res.Body)
+		require.ErrorIs(t, err, zstd.ErrWindowSizeExceeded)
+		require.NoError(t, res.Body.Close())
+	})
+
+	t.Run("decodes zstd in resource and frame queries", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			body := rawBody
+			if req.URL.Path == "/api/v1/query_range" {
+				body = []byte(matrixResponse)
+			}
+			res := responseWithBody("zstd", compress(t, "zstd", body))
+			res.Header.Set("Content-Type", "application/json")
+			return res, nil
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+		res, err := client.QueryResource(context.Background(), &backend.CallResourceRequest{Path: "api/v1/metadata", URL: "api/v1/metadata"})
+		require.NoError(t, err)
+		require.Equal(t, rawBody, readBody(t, res))
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.NotEmpty(t, frames)
+	})
+
+	t.Run("reads body up to limit", func(t *testing.T) {
+		res, err := decompress(responseWithBody("gzip", compress(t, "gzip", rawBody)), int64(len(rawBody)))
+		require.NoError(t, err)
+		require.Equal(t, rawBody, readBody(t, res))
+	})
+
+	t.Run("applies limit set on client", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 1<<16)
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			return responseWithBody("gzip", compress(t, "gzip", data)), nil
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithMaxDecompressedSize(100))
+		res, err := client.Metadata(context.Background(), "", 0)
+		require.NoError(t, err)
+		_, err = io.ReadAll(res.Body)
+		require.ErrorIs(t, err, ErrDecompressedSizeExceeded)
+	})
+}
+
+func TestAcceptEncoding(t *testing.T) {
+	t.Run("asks for gzip by default", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		res, err := newTestClient(t, doer, http.MethodGet, "http://localhost:9090").Metadata(context.Background(), "", 0)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "gzip", doer.LastRequest().Header.Get("Accept-Encoding"))
+	})
+
+	t.Run("asks for zstd when enabled", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		res, err := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithZstd(true)).Metadata(context.Background(), "", 0)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "zstd, gzip", doer.LastRequest().Header.Get("Accept-Encoding"))
+	})
+
+	t.Run("asks for identity when disabled", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		res, err := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithAcceptEncoding(false)).Metadata(context.Background(), "", 0)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "identity", doer.LastRequest().Header.Get("Accept-Encoding"))
+	})
+
+	t.Run("keeps header from context", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		ctx := WithHeaders(context.Background(), http.Header{"Accept-Encoding": {"br"}})
+		res, err := newTestClient(t, doer, http.MethodGet, "http://localhost:9090").Metadata(ctx, "", 0)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "br", doer.LastRequest().Header.Get("Accept-Encoding"))
+	})
+
+	t.Run("only sniffs gzip when disabled", func(t *testing.T) {
+		responses := map[string]*http.Response{
+			"sniffed gzip":          responseWithBody("", compress(t, "gzip", rawBody)),
+			"gzip despite identity": responseWithBody("gzip", compress(t, "gzip", rawBody)),
+			"plain":                 responseWithBody("", rawBody),
+		}
+		for name, r := range responses {
+			t.Run(name, func(t *testing.T) {
+				doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) { return r, nil })
+				client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithAcceptEncoding(false))
+				res, err := client.Metadata(context.Background(), "", 0)
+				require.NoError(t, err)
+				require.Equal(t, rawBody, readBody(t, res))
+				require.Empty(t, res.Header.Get("Content-Encoding"))
+			})
+		}
+
+		compressed := compress(t, "br", rawBody)
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) { return responseWithBody("br", compressed), nil })
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithAcceptEncoding(false))
+		res, err := client.Metadata(context.Background(), "", 0)
+		require.NoError(t, err)
+		require.Equal(t, compressed, readBody(t, res))
+	})
+}
+
+func TestCorruptGzipFallback(t *testing.T) {
+	compressed := compress(t, "gzip", rawBody)
+	truncated := compressed[:len(compressed)-10]
+	metadata := func(t *testing.T, res *http.Response, opts ...Option) (*http.Response, error) {
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) { return res, nil })
+		return newTestClient(t, doer, http.MethodGet, "http://localhost:9090", opts...).Metadata(context.Background(), "", 0)
+	}
+
+	t.Run("fails on truncated gzip by default", func(t *testing.T) {
+		res, err := metadata(t, responseWithBody("gzip", truncated))
+		require.NoError(t, err)
+		_, err = io.ReadAll(res.Body)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("returns truncated gzip as received", func(t *testing.T) {
+		logger := &recordingLogger{}
+		res, err := metadata(t, responseWithBody("gzip", truncated), WithCorruptGzipFallback(true), WithLogger(logger))
+		require.NoError(t, err)
+		require.Equal(t, truncated, readBody(t, res))
+		require.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+		require.NotEmpty(t, logger.warnings)
+	})
+
+	t.Run("returns invalid gzip header as received", func(t *testing.T) {
+		res, err := metadata(t, responseWithBody("gzip", rawBody), WithCorruptGzipFallback(true))
+		require.NoError(t, err)
+		require.Equal(t, rawBody, readBody(t, res))
+	})
+
+	t.Run("returns invalid zstd as received", func(t *testing.T) {
+		res, err := metadata(t, responseWithBody("zstd", rawBody), WithCorruptGzipFallback(true))
+		require.NoError(t, err)
+		require.Equal(t, rawBody, readBody(t, res))
+		require.Equal(t, "zstd", res.Header.Get("Content-Encoding"))
+	})
+
+	t.Run("decompresses valid gzip", func(t *testing.T) {
+		res, err := metadata(t, responseWithBody("gzip", compressed), WithCorruptGzipFallback(true))
+		require.NoError(t, err)
+		require.Equal(t, rawBody, readBody(t, res))
+		require.Empty(t, res.Header.Get("Content-Encoding"))
+	})
+
+	t.Run("decompresses double gzip", func(t *testing.T) {
+		res, err := metadata(t, responseWithBody("gzip", compress(t, "gzip", compressed)), WithCorruptGzipFallback(true))
+		require.NoError(t, err)
+		require.Equal(t, rawBody, readBody(t, res))
+	})
+
+	t.Run("keeps size limit", func(t *testing.T) {
+		_, err := metadata(t, responseWithBody("gzip", compressed), WithCorruptGzipFallback(true), WithMaxDecompressedSize(10))
+		require.ErrorIs(t, err, ErrDecompressedSizeExceeded)
+	})
+}
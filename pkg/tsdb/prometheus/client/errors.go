@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Error types returned by the Prometheus API. See https://prometheus.io/docs/prometheus/latest/querying/api/#format-overview
+const (
+	ErrorTypeTimeout     = "timeout"
+	ErrorTypeCanceled    = "canceled"
+	ErrorTypeExecution   = "execution"
+	ErrorTypeBadData     = "bad_data"
+	ErrorTypeInternal    = "internal"
+	ErrorTypeUnavailable = "unavailable"
+	ErrorTypeNotFound    = "not_found"
+)
+
+// maxErrorBodySize limits how much of an error response is read when looking for the error envelope.
+const maxErrorBodySize = 1 << 20
+
+// ErrRedirected is returned for queries answered with a redirect that was not followed, typically by a gateway
+// redirecting unauthenticated requests to a login page. See WithMaxRedirects.
+var ErrRedirected = errors.New("query was redirected")
+
+// ErrQueryTimeout is returned when Prometheus aborts the evaluation of a query because it took longer than the query
+// timeout of the server, so increasing the timeout of the query may help. The error is a PrometheusError.
+var ErrQueryTimeout = errors.New("query timed out on the Prometheus server")
+
+// ErrRequestTimeout is returned when the context deadline of a request, or the timeout set with WithDefaultTimeout,
+// expires before Prometheus responds. It wraps context.DeadlineExceeded.
+var ErrRequestTimeout = fmt.Errorf("request to Prometheus timed out: %w", context.DeadlineExceeded)
+
+// PrometheusError is an error response of the Prometheus API. Responses without the standard error envelope, e.g. error
+// pages of proxies, have no Type and their body as Message.
+type PrometheusError struct {
+	// Type is the errorType of the response, e.g. bad_data or timeout.
+	Type string
+	// Message is the error message returned by Prometheus, or the body of responses without error envelope.
+	Message string
+	// Status is the HTTP status code of the response.
+	Status int
+}
+
+func (e *PrometheusError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("%s: %s", e.Type, e.Message)
+	}
+	status := fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+	if e.Message == "" {
+		return status
+	}
+	return fmt.Sprintf("%s: %s", status, e.Message)
+}
+
+// Is reports timeouts of the query evaluation on the server as ErrQueryTimeout.
+func (e *PrometheusError) Is(target error) bool {
+	return target == ErrQueryTimeout && e.Type == ErrorTypeTimeout
+}
+
+type errorEnvelope struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+}
+
+// checkError returns a PrometheusError for responses with a 4xx or 5xx status, and ErrRedirected for 3xx responses.
+// The error has the type and message of the Prometheus error envelope, or the body as message if the response has
+// none, so callers never parse error responses. Other responses are returned unchanged, so callers can read the body.
+func checkError(res *http.Response) (*http.Response, error) {
+	if res.StatusCode >= http.StatusMultipleChoices && res.StatusCode < http.StatusBadRequest {
+		drainAndClose(res.Body)
+		return nil, fmt.Errorf("%w: status %d to %q", ErrRedirected, res.StatusCode, res.Header.Get("Location"))
+	}
+
+	if res.StatusCode < http.StatusBadRequest {
+		return res, nil
+	}
+
+	var body []byte
+	if res.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+		_ = res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var envelope errorEnvelope
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Status == "error" {
+		return nil, &PrometheusError{
+			Type:    envelope.ErrorType,
+			Message: envelope.Error,
+			Status:  res.StatusCode,
+		}
+	}
+
+	// Not a Prometheus error, e.g. an error page of a proxy. The beginning of the body is enough to tell what it is.
+	if len(body) > maxDecodeErrorBodySize {
+		body = body[:maxDecodeErrorBodySize]
+	}
+	return nil, &PrometheusError{
+		Message: strings.TrimSpace(string(body)),
+		Status:  res.StatusCode,
+	}
+}
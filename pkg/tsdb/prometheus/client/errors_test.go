@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestCheckError(t *testing.T) {
+	t.Run("returns PrometheusError for error envelope", func(t *testing.T) {
+		res := &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Body:       io.NopCloser(strings.NewReader(`{"status":"error","errorType":"bad_data","error":"invalid parameter \"query\""}`)),
+		}
+		_, err := checkError(res)
+		var promErr *PrometheusError
+		require.True(t, errors.As(err, &promErr))
+		require.Equal(t, ErrorTypeBadData, promErr.Type)
+		require.Equal(t, `invalid parameter "query"`, promErr.Message)
+		require.Equal(t, http.StatusBadRequest, promErr.Status)
+		require.Equal(t, `bad_data: invalid parameter "query"`, err.Error())
+	})
+
+	t.Run("returns PrometheusError for server side timeout", func(t *testing.T) {
+		res := &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Body:       io.NopCloser(strings.NewReader(`{"status":"error","errorType":"timeout","error":"query timed out in expression evaluation"}`)),
+		}
+		_, err := checkError(res)
+		var promErr *PrometheusError
+		require.True(t, errors.As(err, &promErr))
+		require.Equal(t, ErrorTypeTimeout, promErr.Type)
+		require.Equal(t, http.StatusServiceUnavailable, promErr.Status)
+		require.ErrorIs(t, err, ErrQueryTimeout)
+		require.NotErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("returns status error with body of non Prometheus errors", func(t *testing.T) {
+		res := &http.Response{
+			StatusCode: http.StatusBadGateway,
+			Body:       io.NopCloser(strings.NewReader("<html>502 Bad Gateway</html>\n")),
+		}
+		_, err := checkError(res)
+		var promErr *PrometheusError
+		require.ErrorAs(t, err, &promErr)
+		require.Empty(t, promErr.Type)
+		require.Equal(t, "<html>502 Bad Gateway</html>", promErr.Message)
+		require.Equal(t, http.StatusBadGateway, promErr.Status)
+		require.Equal(t, "502 Bad Gateway: <html>502 Bad Gateway</html>", err.Error())
+	})
+
+	t.Run("ignores successful responses", func(t *testing.T) {
+		res := &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"status":"success","data":{"resultType":"vector","result":[]}}`)),
+		}
+		res, err := checkError(res)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, `{"status":"success","data":{"resultType":"vector","result":[]}}`, string(body))
+	})
+
+	t.Run("returns status error for error responses without body", func(t *testing.T) {
+		for _, body := range []io.ReadCloser{nil, http.NoBody} {
+			_, err := checkError(&http.Response{StatusCode: http.StatusBadRequest, Body: body})
+			var promErr *PrometheusError
+			require.ErrorAs(t, err, &promErr)
+			require.Equal(t, http.StatusBadRequest, promErr.Status)
+			require.Equal(t, "400 Bad Request", err.Error())
+		}
+	})
+}
+
+func TestTimeoutErrors(t *testing.T) {
+	query := &models.Query{Expr: "up", Start: time.Unix(0, 0), End: time.Unix(100, 0), Step: time.Second}
+	blocking := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, &url.Error{Op: "Post", URL: req.URL.String(), Err: req.Context().Err()}
+	})
+
+	t.Run("returns ErrQueryTimeout for server side timeouts", func(t *testing.T) {
+		body := `{"status":"error","errorType":"timeout","error":"query timed out in expression evaluation"}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusServiceUnavailable, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		_, err := client.QueryRange(context.Background(), query)
+		require.ErrorIs(t, err, ErrQueryTimeout)
+		require.NotErrorIs(t, err, ErrRequestTimeout)
+	})
+
+	t.Run("does not return ErrQueryTimeout for other errors", func(t *testing.T) {
+		body := `{"status":"error","errorType":"execution","error":"many-to-many matching not allowed"}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusUnprocessableEntity, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		_, err := client.QueryRange(context.Background(), query)
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrQueryTimeout)
+	})
+
+	t.Run("returns ErrRequestTimeout when the context deadline expires", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := newTestClient(t, blocking, http.MethodGet, "http://localhost:9090").QueryRange(ctx, query)
+		require.ErrorIs(t, err, ErrRequestTimeout)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotErrorIs(t, err, ErrQueryTimeout)
+	})
+
+	t.Run("returns ErrRequestTimeout when the default timeout expires", func(t *testing.T) {
+		client := newTestClient(t, blocking, http.MethodGet, "http://localhost:9090", WithDefaultTimeout(10*time.Millisecond))
+
+		_, err := client.QueryRange(context.Background(), query)
+		require.ErrorIs(t, err, ErrRequestTimeout)
+	})
+
+	t.Run("does not return ErrRequestTimeout when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := newTestClient(t, blocking, http.MethodGet, "http://localhost:9090").QueryRange(ctx, query)
+		require.ErrorIs(t, err, context.Canceled)
+		require.NotErrorIs(t, err, ErrRequestTimeout)
+	})
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+// WithGETFallback sends a query again with GET if a POST of it was rejected by a proxy, for networks where proxies
+// block POST requests to the query endpoints. Only 405 responses and 403 responses that are not Prometheus errors
+// trigger the fallback, so errors of the query itself are returned as they are.
+func WithGETFallback(enabled bool) Option {
+	return func(c *Client) {
+		c.getFallback = enabled
+	}
+}
+
+// doQuery sends a query with the given parameters and, with WithGETFallback, sends it again with GET if the POST
+// request was blocked.
+func (c *Client) doQuery(ctx context.Context, q *models.Query, endpoint string, qv map[string]string) (*http.Response, error) {
+	req, err := c.createQueryRequest(ctx, q.Method, endpoint, qv)
+	if err != nil {
+		return nil, err
+	}
+	setQueryTags(req, q.QueryTags)
+
+	res, err := c.do(req)
+	if err != nil || !c.getFallback || req.Method != http.MethodPost || !postBlocked(res) {
+		return res, err
+	}
+	drainAndClose(res.Body)
+
+	c.logger.FromContext(ctx).Debug("POST request was blocked, sending it with GET", "endpoint", endpoint, "status", res.StatusCode)
+	req, err = c.createQueryRequest(ctx, http.MethodGet, endpoint, qv)
+	if err != nil {
+		return nil, err
+	}
+	setQueryTags(req, q.QueryTags)
+	return c.do(req)
+}
+
+// postBlocked returns whether the response rejects the method of the request rather than the query. Prometheus does
+// not return 403 itself, but its error responses are checked anyway in case a proxy in front of it does.
+func postBlocked(res *http.Response) bool {
+	switch res.StatusCode {
+	case http.StatusMethodNotAllowed:
+		return true
+	case http.StatusForbidden:
+		mediaType, _, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		return err != nil || !strings.EqualFold(mediaType, "application/json")
+	default:
+		return false
+	}
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+// byMethod responds with the response configured for the method of the request and an empty matrix as body.
+func byMethod(responses map[string]*http.Response) clienttest.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		res := *responses[req.Method]
+		res.Body = io.NopCloser(strings.NewReader(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		return &res, nil
+	}
+}
+
+// requestMethods returns the methods of the requests the doer received.
+func requestMethods(doer *clienttest.FakeDoer) []string {
+	requests := doer.Requests()
+	methods := make([]string, 0, len(requests))
+	for _, req := range requests {
+		methods = append(methods, req.Method)
+	}
+	return methods
+}
+
+func TestGETFallback(t *testing.T) {
+	query := &models.Query{Expr: "up", Start: time.Unix(0, 0), End: time.Unix(1234, 0), Step: time.Second, RangeQuery: true}
+	ok := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}}
+	blocked := func(status int, contentType string) *http.Response {
+		return &http.Response{StatusCode: status, Header: http.Header{"Content-Type": {contentType}}}
+	}
+
+	t.Run("sends blocked POST range queries with GET", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(byMethod(map[string]*http.Response{http.MethodPost: blocked(http.StatusMethodNotAllowed, "text/html"), http.MethodGet: ok}))
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090", WithGETFallback(true))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, []string{http.MethodPost, http.MethodGet}, requestMethods(doer))
+		require.Equal(t, "http://localhost:9090/api/v1/query_range?end=1234&query=up&start=0&step=1", doer.Requests()[1].URL.String())
+	})
+
+	t.Run("sends POST instant queries blocked with 403 with GET", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(byMethod(map[string]*http.Response{http.MethodPost: blocked(http.StatusForbidden, "text/html; charset=utf-8"), http.MethodGet: ok}))
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090", WithGETFallback(true))
+
+		res, err := client.QueryInstant(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, []string{http.MethodPost, http.MethodGet}, requestMethods(doer))
+	})
+
+	t.Run("does not fall back on Prometheus errors", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(byMethod(map[string]*http.Response{http.MethodPost: blocked(http.StatusForbidden, "application/json"), http.MethodGet: ok}))
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090", WithGETFallback(true))
+
+		_, err := client.QueryRange(context.Background(), query)
+		var promErr *PrometheusError
+		require.ErrorAs(t, err, &promErr)
+		require.Equal(t, http.StatusForbidden, promErr.Status)
+		require.Equal(t, []string{http.MethodPost}, requestMethods(doer))
+	})
+
+	t.Run("does not fall back on other client errors", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(byMethod(map[string]*http.Response{http.MethodPost: blocked(http.StatusBadRequest, "text/plain"), http.MethodGet: ok}))
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090", WithGETFallback(true))
+
+		_, err := client.QueryRange(context.Background(), query)
+		var promErr *PrometheusError
+		require.ErrorAs(t, err, &promErr)
+		require.Equal(t, http.StatusBadRequest, promErr.Status)
+		require.Equal(t, []string{http.MethodPost}, requestMethods(doer))
+	})
+
+	t.Run("does not fall back by default", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(byMethod(map[string]*http.Response{http.MethodPost: blocked(http.StatusMethodNotAllowed, "text/html"), http.MethodGet: ok}))
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090")
+
+		_, err := client.QueryRange(context.Background(), query)
+		var promErr *PrometheusError
+		require.ErrorAs(t, err, &promErr)
+		require.Equal(t, http.StatusMethodNotAllowed, promErr.Status)
+		require.Equal(t, []string{http.MethodPost}, requestMethods(doer))
+	})
+
+	t.Run("does not fall back for GET requests", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(byMethod(map[string]*http.Response{http.MethodGet: blocked(http.StatusMethodNotAllowed, "text/html")}))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithGETFallback(true))
+
+		_, err := client.QueryRange(context.Background(), query)
+		var promErr *PrometheusError
+		require.ErrorAs(t, err, &promErr)
+		require.Equal(t, http.StatusMethodNotAllowed, promErr.Status)
+		require.Equal(t, []string{http.MethodGet}, requestMethods(doer))
+	})
+}
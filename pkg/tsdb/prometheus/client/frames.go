@@ -0,0 +1,308 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/converter"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+// Result is a parsed query result.
+type Result struct {
+	Frames data.Frames
+	// Warnings are the warnings of the response, e.g. about partial results. They are also attached to the notices
+	// of every frame.
+	Warnings []string
+	// Infos are the informational annotations of the response, e.g. about a rate over a metric without a counter
+	// suffix.
+	Infos []string
+	// FromCache is set if the response was served from the cache set with WithCache.
+	FromCache bool
+	// Stats are the statistics of the query if models.Query.Stats is set and the server returned them, nil otherwise.
+	Stats *QueryStats
+	// Explanation is the query plan if models.Query.Explain is set and the server returned it, nil otherwise.
+	Explanation *QueryExplanation
+	// Header holds the response headers allowed with WithResponseHeaders, nil if there are none.
+	Header http.Header
+	// Query is a copy of the query that produced the result, with the time range that was sent to Prometheus.
+	Query *models.Query
+}
+
+// WithRawValues adds a string field with the sample values as sent by Prometheus to the frames of parsed results, for
+// values that lose precision as float64.
+func WithRawValues(enabled bool) Option {
+	return func(c *Client) {
+		c.rawValues = enabled
+	}
+}
+
+// WithHistogramTotals adds a frame with the count and sum of every sample next to the heatmap-cells frame of native
+// histogram series in parsed results.
+func WithHistogramTotals(enabled bool) Option {
+	return func(c *Client) {
+		c.histogramTotals = enabled
+	}
+}
+
+// WithSortedSeries sorts the frames of parsed results by the labels of their series, so the order does not depend on
+// the order of the response and legends do not change between refreshes. Frames with identical labels keep their
+// order.
+func WithSortedSeries(enabled bool) Option {
+	return func(c *Client) {
+		c.sortSeries = enabled
+	}
+}
+
+// QueryRangeResult runs a range query like QueryRangeFrames and also returns the warnings of the response.
+func (c *Client) QueryRangeResult(ctx context.Context, q *models.Query) (*Result, error) {
+	res, err := c.QueryRange(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	query := copyQuery(q)
+	tr := c.timeRange(q)
+	query.Start, query.End, query.Step = tr.Start, tr.End, tr.Step
+	return c.readResult(res, query)
+}
+
+// QueryInstantResult runs an instant query like QueryInstantFrames and also returns the warnings of the response.
+func (c *Client) QueryInstantResult(ctx context.Context, q *models.Query) (*Result, error) {
+	res, err := c.QueryInstant(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.readResult(res, copyQuery(q))
+}
+
+// readResult parses the frames of a query response and closes its body.
+func (c *Client) readResult(res *http.Response, query *models.Query) (*Result, error) {
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	frames, env, err := readFrames(res, converter.Options{RawValues: c.rawValues, HistogramTotals: c.histogramTotals})
+	if err != nil {
+		return nil, err
+	}
+	stats, err := envelopeStats(env)
+	if err != nil {
+		return nil, err
+	}
+	explanation, err := envelopeExplanation(env)
+	if err != nil {
+		return nil, err
+	}
+	if c.sortSeries {
+		sortFrames(frames)
+	}
+	if c.tableFrames {
+		frames = tableFrames(frames)
+	}
+
+	return &Result{
+		Frames:      frames,
+		Warnings:    env.Warnings,
+		Infos:       env.Infos,
+		FromCache:   res.Header.Get(CacheHeader) == cacheHit,
+		Stats:       stats,
+		Explanation: explanation,
+		Header:      c.resultHeader(res),
+		Query:       query,
+	}, nil
+}
+
+// copyQuery returns a copy of q that does not share the scope matchers, extra parameters and query tags with it, so
+// that changes to the copy do not affect the caller's query.
+func copyQuery(q *models.Query) *models.Query {
+	cp := *q
+	cp.ExtraParams = maps.Clone(q.ExtraParams)
+	cp.QueryTags = maps.Clone(q.QueryTags)
+	if q.Scope.Matchers != nil {
+		cp.Scope.Matchers = make([]*labels.Matcher, len(q.Scope.Matchers))
+		for i, m := range q.Scope.Matchers {
+			mc := *m
+			cp.Scope.Matchers[i] = &mc
+		}
+	}
+	return &cp
+}
+
+// QueryRangeFrames runs a range query and parses the matrix or vector result into data frames. Unlike QueryRange,
+// the response body is read and closed by the client. Errors returned by Prometheus are returned as PrometheusError.
+func (c *Client) QueryRangeFrames(ctx context.Context, q *models.Query) (data.Frames, error) {
+	r, err := c.QueryRangeResult(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Frames, nil
+}
+
+// QueryInstantFrames runs an instant query and parses the result into data frames. Vector results have a frame per
+// series, scalar results a single numeric frame and string results a single frame with a string value, each with
+// the time of the sample.
+func (c *Client) QueryInstantFrames(ctx context.Context, q *models.Query) (data.Frames, error) {
+	r, err := c.QueryInstantResult(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Frames, nil
+}
+
+// maxDecodeErrorBodySize is how much of the body is included in a DecodeError.
+const maxDecodeErrorBodySize = 512
+
+// DecodeError is returned when a response body cannot be parsed. It includes the beginning of the body, so it is
+// easy to tell for example an HTML error page of a proxy from truncated JSON.
+type DecodeError struct {
+	Status      int
+	ContentType string
+	// Body holds the first bytes of the response body.
+	Body string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode response with status %d and content type %q: %v, body: %q", e.Status, e.ContentType, e.Err, e.Body)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// readFrames parses the frames of a response and returns them with its envelope.
+func readFrames(res *http.Response, opts converter.Options) (data.Frames, converter.Envelope, error) {
+	r := frameReaderPool.Get().(*frameReader)
+	frames, env, err := r.read(res, opts)
+	r.release(err == nil)
+	return frames, env, err
+}
+
+// frameReader holds the buffers needed to parse a response body. They are pooled, so parsing the responses of busy
+// dashboards does not allocate them for every response.
+type frameReader struct {
+	body   *bufio.Reader
+	prefix prefixReader
+	iter   *jsoniter.Iterator
+}
+
+var frameReaderPool = sync.Pool{
+	New: func() any {
+		return &frameReader{
+			body:   bufio.NewReader(nil),
+			prefix: prefixReader{size: maxDecodeErrorBodySize},
+			iter:   jsoniter.Parse(jsoniter.ConfigDefault, nil, 1024),
+		}
+	},
+}
+
+func (r *frameReader) read(res *http.Response, opts converter.Options) (data.Frames, converter.Envelope, error) {
+	// Some endpoints respond with 204 or an empty body when there is no data.
+	r.body.Reset(res.Body)
+	if _, err := r.body.Peek(1); errors.Is(err, io.EOF) && res.StatusCode >= 200 && res.StatusCode < 300 {
+		return data.Frames{}, converter.Envelope{}, nil
+	}
+
+	r.prefix.r = r.body
+	decodeError := func(err error) error {
+		// Fill the prefix in case decoding failed before reading it.
+		_, _ = io.CopyN(io.Discard, &r.prefix, maxDecodeErrorBodySize)
+		return &DecodeError{Status: res.StatusCode, ContentType: res.Header.Get("Content-Type"), Body: r.prefix.buf.String(), Err: err}
+	}
+
+	if err := checkContentType(res); err != nil {
+		return nil, converter.Envelope{}, decodeError(err)
+	}
+
+	rsp, env := converter.ReadPrometheusStyleResultEnvelope(r.iter.Reset(&r.prefix), opts)
+	if rsp.Error != nil {
+		return nil, converter.Envelope{}, decodeError(rsp.Error)
+	}
+
+	return rsp.Frames, env, nil
+}
+
+// release drops the references to the response and returns the reader to the pool. Readers that failed are not
+// reused, as the iterator may be left in the middle of a value.
+func (r *frameReader) release(reuse bool) {
+	r.body.Reset(nil)
+	r.prefix.r = nil
+	r.prefix.buf.Reset()
+	r.iter.Reset(nil)
+	if reuse {
+		frameReaderPool.Put(r)
+	}
+}
+
+// prefixReader keeps a copy of the first size bytes read.
+type prefixReader struct {
+	r    io.Reader
+	buf  bytes.Buffer
+	size int
+}
+
+func (p *prefixReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if remaining := p.size - p.buf.Len(); remaining > 0 {
+		p.buf.Write(b[:min(n, remaining)])
+	}
+	return n, err
+}
+
+// checkContentType makes sure the response is JSON, the only format the Prometheus API returns query results in.
+// Responses without a Content-Type are assumed to be JSON as well.
+func checkContentType(res *http.Response) error {
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid response content type %q: %w", contentType, err)
+	}
+
+	if mediaType != "application/json" {
+		return fmt.Errorf("unsupported response content type %q", mediaType)
+	}
+	return nil
+}
+
+// sortFrames sorts frames by the labels of their values, in the order Prometheus compares label sets. The sort is
+// stable, so frames with identical labels keep the order of the response.
+func sortFrames(frames data.Frames) {
+	keys := make(map[*data.Frame]labels.Labels, len(frames))
+	for _, frame := range frames {
+		keys[frame] = labels.FromMap(frameLabels(frame))
+	}
+	sort.SliceStable(frames, func(i, j int) bool {
+		return labels.Compare(keys[frames[i]], keys[frames[j]]) < 0
+	})
+}
+
+// frameLabels returns the labels of the first field with labels, which is the value field of a series.
+func frameLabels(frame *data.Frame) data.Labels {
+	for _, field := range frame.Fields {
+		if field.Labels != nil {
+			return field.Labels
+		}
+	}
+	return nil
+}
@@ -0,0 +1,461 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/converter"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+var rangeQuery = &models.Query{
+	Expr:       "up",
+	Start:      time.Unix(1641889530, 0),
+	End:        time.Unix(1641889538, 0),
+	RangeQuery: true,
+	Step:       1 * time.Second,
+}
+
+const matrixResponse = `{
+	"status": "success",
+	"data": {
+		"resultType": "matrix",
+		"result": [
+			{
+				"metric": {"__name__": "up", "job": "prometheus"},
+				"values": [[1641889530, "1"], [1641889531, "0"]]
+			},
+			{
+				"metric": {"__name__": "up", "job": "node"},
+				"values": [[1641889530, "1"]]
+			}
+		]
+	}
+}`
+
+func TestQueryRangeFrames(t *testing.T) {
+	t.Run("parses matrix into frames", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090")
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, frames, 2)
+		require.Equal(t, 2, frames[0].Rows())
+		require.Equal(t, "prometheus", frames[0].Fields[1].Labels["job"])
+		require.Equal(t, 1, frames[1].Rows())
+		require.Equal(t, "node", frames[1].Fields[1].Labels["job"])
+	})
+
+	t.Run("keeps order of the response by default", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090")
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Equal(t, "prometheus", frames[0].Fields[1].Labels["job"])
+	})
+
+	t.Run("sorts series by labels when enabled", func(t *testing.T) {
+		body := `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"job":"prometheus"},"values":[[1641889530,"1"]]},
+			{"metric":{"job":"node","instance":"b"},"values":[[1641889530,"2"]]},
+			{"metric":{"job":"api"},"values":[[1641889530,"3"]]},
+			{"metric":{"job":"node","instance":"a"},"values":[[1641889530,"4"]]},
+			{"metric":{"job":"api"},"values":[[1641889530,"5"]]}
+		]}}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090", WithSortedSeries(true))
+
+		for i := 0; i < 3; i++ {
+			frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+			require.NoError(t, err)
+			require.Len(t, frames, 5)
+
+			var values []float64
+			for _, frame := range frames {
+				values = append(values, frame.Fields[1].At(0).(float64))
+			}
+			// Label sets are compared by sorted label names, so instance comes before job. Series with identical labels
+			// keep the order of the response.
+			require.Equal(t, []float64{4, 2, 3, 5, 1}, values)
+		}
+	})
+
+	t.Run("returns PrometheusError for error envelope", func(t *testing.T) {
+		body := `{"status":"error","errorType":"bad_data","error":"1:1: parse error: unexpected end of input"}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusBadRequest, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		_, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		var promErr *PrometheusError
+		require.True(t, errors.As(err, &promErr))
+		require.Equal(t, ErrorTypeBadData, promErr.Type)
+	})
+
+	t.Run("returns error for invalid body", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, `{"status": "success", "data": `, nil)), http.MethodGet, "http://localhost:9090")
+
+		_, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.Error(t, err)
+	})
+
+	t.Run("parses JSON with charset", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, http.Header{"Content-Type": {"application/json; charset=utf-8"}}))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, frames, 2)
+	})
+
+	t.Run("returns error for unsupported content type", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, "\x0a\x04", http.Header{"Content-Type": {"application/x-protobuf"}}))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+		_, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.ErrorContains(t, err, `unsupported response content type "application/x-protobuf"`)
+	})
+
+	t.Run("includes the beginning of error pages in errors", func(t *testing.T) {
+		page := "<html><head><title>502 Bad Gateway</title></head><body>" + strings.Repeat("x", 1024) + "</body></html>"
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusBadGateway, page, http.Header{"Content-Type": {"text/html"}}))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+		_, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		var promErr *PrometheusError
+		require.ErrorAs(t, err, &promErr)
+		require.Equal(t, http.StatusBadGateway, promErr.Status)
+		require.Equal(t, page[:maxDecodeErrorBodySize], promErr.Message)
+		require.ErrorContains(t, err, "502 Bad Gateway")
+	})
+
+	t.Run("includes body of truncated JSON in decode errors", func(t *testing.T) {
+		body := `{"status": "success", "data": {"resultType": "matrix", "result": [{"metric": {`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		_, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		var decodeErr *DecodeError
+		require.ErrorAs(t, err, &decodeErr)
+		require.Equal(t, http.StatusOK, decodeErr.Status)
+		require.Equal(t, body, decodeErr.Body)
+	})
+
+	t.Run("returns no frames for empty responses", func(t *testing.T) {
+		for _, status := range []int{http.StatusOK, http.StatusNoContent} {
+			doer := clienttest.NewFakeDoer(clienttest.Respond(status, "", http.Header{}))
+			client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+			frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+			require.NoError(t, err)
+			require.Empty(t, frames)
+		}
+	})
+
+	t.Run("returns error for empty error responses", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusBadGateway, "", nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+		_, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.Error(t, err)
+	})
+
+	t.Run("adds raw values when enabled", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090", WithRawValues(true))
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, frames[0].Fields, 3)
+		require.Equal(t, converter.RawValueFieldName, frames[0].Fields[2].Name)
+		require.Equal(t, "0", frames[0].Fields[2].At(1))
+	})
+
+	t.Run("returns no frames for null results", func(t *testing.T) {
+		body := `{"status":"success","data":{"resultType":"matrix","result":null}}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Empty(t, frames)
+	})
+
+	t.Run("parses native histograms", func(t *testing.T) {
+		body, err := os.ReadFile("../converter/testdata/prom-matrix-histogram-no-labels.json")
+		require.NoError(t, err)
+
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, string(body), nil)), http.MethodGet, "http://localhost:9090")
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+		require.Equal(t, data.FrameType("heatmap-cells"), frames[0].Meta.Type)
+
+		client = newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, string(body), nil)), http.MethodGet, "http://localhost:9090", WithHistogramTotals(true))
+		frames, err = client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, frames, 2)
+		require.Equal(t, converter.HistogramCountFieldName, frames[1].Fields[1].Name)
+		require.Equal(t, converter.HistogramSumFieldName, frames[1].Fields[2].Name)
+		require.InDelta(t, 316.9547490576795, frames[1].Fields[1].At(0).(float64), 1e-9)
+	})
+}
+
+func TestQueryRangeResult(t *testing.T) {
+	t.Run("returns warnings of the response", func(t *testing.T) {
+		body := strings.Replace(matrixResponse, `"status": "success",`, `"status": "success", "warnings": ["results may be incomplete", "another warning"],`, 1)
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		result, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, result.Frames, 2)
+		require.Equal(t, []string{"results may be incomplete", "another warning"}, result.Warnings)
+	})
+
+	t.Run("returns warning of truncated results", func(t *testing.T) {
+		body := strings.Replace(matrixResponse, `"status": "success",`, `"status": "success", "warnings": ["results truncated due to limit"],`, 1)
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		q := *rangeQuery
+		q.Limit = 2
+
+		result, err := client.QueryRangeResult(context.Background(), &q)
+		require.NoError(t, err)
+		require.Equal(t, "2", doer.LastRequest().URL.Query().Get("limit"))
+		require.Equal(t, []string{"results truncated due to limit"}, result.Warnings)
+		require.Equal(t, 2, result.Query.Limit)
+	})
+
+	t.Run("returns warnings, infos, stats and explanation of responses without series", func(t *testing.T) {
+		body := `{
+			"status": "success",
+			"warnings": ["results may be incomplete"],
+			"infos": ["metric might not be a counter"],
+			"data": {
+				"resultType": "matrix",
+				"result": [],
+				"stats": {"timings": {"evalTotalTime": 0.5}},
+				"explanation": {"name": "[vectorSelector] {[__name__=\"up\"]}"}
+			}
+		}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		result, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Empty(t, result.Frames)
+		require.Equal(t, []string{"results may be incomplete"}, result.Warnings)
+		require.Equal(t, []string{"metric might not be a counter"}, result.Infos)
+		require.NotNil(t, result.Stats)
+		require.Equal(t, 0.5, result.Stats.Timings.EvalTotalTime)
+		require.Equal(t, &QueryExplanation{Name: `[vectorSelector] {[__name__="up"]}`}, result.Explanation)
+	})
+
+	t.Run("returns no warnings when there are none", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090")
+
+		result, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, result.Frames, 2)
+		require.Empty(t, result.Warnings)
+	})
+
+	t.Run("returns a copy of the query", func(t *testing.T) {
+		q := *rangeQuery
+		q.Scope.Matchers = []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "job", "api")}
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090")
+
+		result, err := client.QueryRangeResult(context.Background(), &q)
+		require.NoError(t, err)
+		require.NotSame(t, &q, result.Query)
+		require.Equal(t, q.Expr, result.Query.Expr)
+		require.True(t, q.Start.Equal(result.Query.Start))
+		require.True(t, q.End.Equal(result.Query.End))
+		require.Equal(t, q.Scope, result.Query.Scope)
+
+		result.Query.Expr = "down"
+		result.Query.Scope.Matchers[0].Value = "db"
+		require.Equal(t, "up", q.Expr)
+		require.Equal(t, "api", q.Scope.Matchers[0].Value)
+	})
+
+	t.Run("does not share the maps of the query", func(t *testing.T) {
+		q := *rangeQuery
+		q.ExtraParams = map[string]string{"engine": "thanos"}
+		q.QueryTags = map[string]string{"dashboard": "a"}
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090")
+
+		result, err := client.QueryRangeResult(context.Background(), &q)
+		require.NoError(t, err)
+		require.Equal(t, q.ExtraParams, result.Query.ExtraParams)
+		require.Equal(t, q.QueryTags, result.Query.QueryTags)
+
+		result.Query.ExtraParams["engine"] = "prometheus"
+		result.Query.QueryTags["panel"] = "1"
+		require.Equal(t, map[string]string{"engine": "thanos"}, q.ExtraParams)
+		require.Equal(t, map[string]string{"dashboard": "a"}, q.QueryTags)
+	})
+
+	t.Run("returns the aligned time range of the query", func(t *testing.T) {
+		q := *rangeQuery
+		q.End = q.End.Add(500 * time.Millisecond)
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090", WithAlignToStep(true))
+
+		result, err := client.QueryRangeResult(context.Background(), &q)
+		require.NoError(t, err)
+		require.True(t, time.Unix(1641889539, 0).Equal(result.Query.End))
+	})
+
+	t.Run("returns query stats when requested", func(t *testing.T) {
+		body := strings.Replace(matrixResponse, `
+		]
+	}`, `
+		],
+		"stats": {
+			"timings": {"evalTotalTime": 0.5, "execTotalTime": 0.75},
+			"samples": {"totalQueryableSamples": 1200, "peakSamples": 300}
+		}
+	}`, 1)
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		q := *rangeQuery
+		q.Stats = true
+
+		result, err := client.QueryRangeResult(context.Background(), &q)
+		require.NoError(t, err)
+		require.Equal(t, "all", doer.LastRequest().URL.Query().Get("stats"))
+		require.Equal(t, &QueryStats{
+			Timings: QueryTimings{EvalTotalTime: 0.5, ExecTotalTime: 0.75},
+			Samples: QuerySamples{TotalQueryableSamples: 1200, PeakSamples: 300},
+		}, result.Stats)
+	})
+
+	t.Run("does not request query stats by default", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+		result, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.False(t, doer.LastRequest().URL.Query().Has("stats"))
+		require.Nil(t, result.Stats)
+	})
+
+	t.Run("returns query explanation when requested", func(t *testing.T) {
+		body := strings.Replace(matrixResponse, `
+		]
+	}`, `
+		],
+		"explanation": {
+			"name": "[concurrent(buff=2)]",
+			"children": [{"name": "[vectorSelector] {[__name__=\"up\"]}"}]
+		},
+		"stats": {
+			"timings": {"evalTotalTime": 0.5}
+		}
+	}`, 1)
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		q := *rangeQuery
+		q.Explain = true
+		q.Stats = true
+
+		result, err := client.QueryRangeResult(context.Background(), &q)
+		require.NoError(t, err)
+		require.Equal(t, "true", doer.LastRequest().URL.Query().Get("explain"))
+		require.Equal(t, &QueryExplanation{
+			Name:     "[concurrent(buff=2)]",
+			Children: []*QueryExplanation{{Name: `[vectorSelector] {[__name__="up"]}`}},
+		}, result.Explanation)
+		require.NotNil(t, result.Stats)
+		require.Equal(t, 0.5, result.Stats.Timings.EvalTotalTime)
+	})
+
+	t.Run("does not request query explanation by default", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+		result, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.False(t, doer.LastRequest().URL.Query().Has("explain"))
+		require.Nil(t, result.Explanation)
+	})
+
+	t.Run("returns errors", func(t *testing.T) {
+		body := `{"status":"error","errorType":"bad_data","error":"1:1: parse error: unexpected end of input"}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusBadRequest, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		_, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		var promErr *PrometheusError
+		require.ErrorAs(t, err, &promErr)
+	})
+}
+
+func TestQueryInstantFrames(t *testing.T) {
+	query := &models.Query{Expr: "scalar(up)", End: time.Unix(1651680139, 0)}
+	recorded := func(t *testing.T, name string) string {
+		body, err := os.ReadFile("../converter/testdata/" + name + ".json")
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	t.Run("parses scalar results", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, recorded(t, "prom-scalar"), nil)), http.MethodGet, "http://localhost:9090")
+
+		frames, err := client.QueryInstantFrames(context.Background(), query)
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+		require.Equal(t, data.FrameTypeNumericMulti, frames[0].Meta.Type)
+		require.Equal(t, 1, frames[0].Rows())
+		require.Equal(t, time.UnixMilli(1651680139104).UTC(), frames[0].Fields[0].At(0))
+		require.Equal(t, 0.00002482, frames[0].Fields[1].At(0))
+	})
+
+	t.Run("parses string results", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, recorded(t, "prom-string"), nil)), http.MethodGet, "http://localhost:9090")
+
+		frames, err := client.QueryInstantFrames(context.Background(), query)
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+		require.Equal(t, 1, frames[0].Rows())
+		require.Equal(t, data.FieldTypeString, frames[0].Fields[1].Type())
+		require.Equal(t, time.UnixMilli(1651680139104).UTC(), frames[0].Fields[0].At(0))
+		require.Equal(t, "example", frames[0].Fields[1].At(0))
+	})
+
+	t.Run("parses vector results", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, recorded(t, "prom-vector"), nil)), http.MethodGet, "http://localhost:9090")
+
+		frames, err := client.QueryInstantFrames(context.Background(), query)
+		require.NoError(t, err)
+		require.NotEmpty(t, frames)
+		require.Equal(t, data.FrameTypeTimeSeriesMulti, frames[0].Meta.Type)
+	})
+
+	t.Run("returns the query of the result", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, recorded(t, "prom-scalar"), nil)), http.MethodGet, "http://localhost:9090")
+
+		r, err := client.QueryInstantResult(context.Background(), query)
+		require.NoError(t, err)
+		require.Equal(t, query, r.Query)
+		require.NotSame(t, query, r.Query)
+	})
+}
+
+func BenchmarkReadFrames(b *testing.B) {
+	body, err := os.ReadFile("../converter/testdata/prom-matrix.json")
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: io.NopCloser(bytes.NewReader(body))}
+		if _, _, err := readFrames(res, converter.Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
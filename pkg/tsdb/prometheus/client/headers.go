@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// QueryTagsHeader carries the tags of a query, see models.Query.QueryTags.
+const QueryTagsHeader = "X-Query-Tags"
+
+type headersKey struct{}
+
+// WithResponseHeaders attaches the given headers of query responses, e.g. X-Ratelimit-Remaining, to parsed results
+// as Result.Header, so callers do not need the raw response to read them. Other headers are not attached. Results
+// served from the cache set with WithCache carry no headers.
+func WithResponseHeaders(names ...string) Option {
+	return func(c *Client) {
+		c.responseHeaders = make([]string, 0, len(names))
+		for _, name := range names {
+			c.responseHeaders = append(c.responseHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// WithHeaders returns a context that attaches the given headers to every request made with it, e.g. to send a tenant
+// ID like X-Scope-OrgID. Headers set by the client itself take precedence over these.
+func WithHeaders(ctx context.Context, h http.Header) context.Context {
+	return context.WithValue(ctx, headersKey{}, h.Clone())
+}
+
+func headersFromContext(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headersKey{}).(http.Header)
+	return h
+}
+
+// applyContextHeaders adds the headers attached to the request context without overriding headers already set on
+// the request.
+func applyContextHeaders(req *http.Request) {
+	for key, values := range headersFromContext(req.Context()) {
+		key = http.CanonicalHeaderKey(key)
+		if _, ok := req.Header[key]; ok {
+			continue
+		}
+		req.Header[key] = append([]string(nil), values...)
+	}
+}
+
+// setQueryTags sets the tags in the X-Query-Tags header. No header is set without tags.
+func setQueryTags(req *http.Request, tags map[string]string) {
+	if value := queryTagsValue(tags); value != "" {
+		req.Header.Set(QueryTagsHeader, value)
+	}
+}
+
+// queryTagsValue returns the tags as comma separated key=value pairs sorted by key, so the header of a query is
+// stable. Tags with an empty key are skipped.
+func queryTagsValue(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		if key == "" {
+			continue
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// resultHeader returns the allowed headers of a response, or nil if it has none of them.
+func (c *Client) resultHeader(res *http.Response) http.Header {
+	var h http.Header
+	for _, name := range c.responseHeaders {
+		values, ok := res.Header[name]
+		if !ok {
+			continue
+		}
+		if h == nil {
+			h = make(http.Header, len(c.responseHeaders))
+		}
+		h[name] = append([]string(nil), values...)
+	}
+	return h
+}
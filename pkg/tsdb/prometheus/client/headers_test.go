@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestApplyContextHeaders(t *testing.T) {
+	t.Run("adds headers from context", func(t *testing.T) {
+		ctx := WithHeaders(context.Background(), http.Header{"X-Scope-Orgid": {"tenant-1"}})
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:9090", nil)
+		require.NoError(t, err)
+
+		applyContextHeaders(req)
+		require.Equal(t, "tenant-1", req.Header.Get("X-Scope-OrgID"))
+	})
+
+	t.Run("does not override headers set by the client", func(t *testing.T) {
+		ctx := WithHeaders(context.Background(), http.Header{"Content-Type": {"application/json"}})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost:9090", nil)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		applyContextHeaders(req)
+		require.Equal(t, []string{"application/x-www-form-urlencoded"}, req.Header.Values("Content-Type"))
+	})
+
+	t.Run("does not share headers between requests", func(t *testing.T) {
+		h := http.Header{"X-Scope-Orgid": {"tenant-1"}}
+		ctx := WithHeaders(context.Background(), h)
+		h.Set("X-Scope-Orgid", "tenant-2")
+
+		first, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:9090", nil)
+		require.NoError(t, err)
+		applyContextHeaders(first)
+		first.Header.Add("X-Scope-Orgid", "modified")
+
+		second, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:9090", nil)
+		require.NoError(t, err)
+		applyContextHeaders(second)
+		require.Equal(t, []string{"tenant-1"}, second.Header.Values("X-Scope-OrgID"))
+
+		unrelated, err := http.NewRequest(http.MethodGet, "http://localhost:9090", nil)
+		require.NoError(t, err)
+		applyContextHeaders(unrelated)
+		require.Empty(t, unrelated.Header.Get("X-Scope-OrgID"))
+	})
+}
+
+func TestHost(t *testing.T) {
+	t.Run("sends host on queries and resource calls", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://10.0.0.1:9090", WithHost("prometheus.example.com"))
+
+		res, err := client.QueryInstant(context.Background(), &models.Query{Expr: "up"})
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "prometheus.example.com", doer.LastRequest().Host)
+		require.Equal(t, "10.0.0.1:9090", doer.LastRequest().URL.Host)
+
+		res, err = client.QueryResource(context.Background(), &backend.CallResourceRequest{Method: http.MethodGet, Path: "/api/v1/labels", URL: "/api/v1/labels"})
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "prometheus.example.com", doer.LastRequest().Host)
+	})
+
+	t.Run("sends host on every attempt", func(t *testing.T) {
+		var hosts []string
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable),
+			clienttest.Status(http.StatusOK),
+		))
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			hosts = append(hosts, req.Host)
+			return next.Do(req)
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://10.0.0.1:9090", WithHost("prometheus.example.com"), WithRetryPolicy(testPolicy))
+
+		res, err := client.Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, []string{"prometheus.example.com", "prometheus.example.com"}, hosts)
+	})
+
+	t.Run("reaches the server with the host", func(t *testing.T) {
+		var host string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host = r.Host
+		}))
+		defer srv.Close()
+
+		res, err := newTestClient(t, nil, http.MethodGet, srv.URL, WithHost("prometheus.example.com")).Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "prometheus.example.com", host)
+	})
+
+	t.Run("keeps URL host by default", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		res, err := newTestClient(t, doer, http.MethodGet, "http://10.0.0.1:9090").Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "10.0.0.1:9090", doer.LastRequest().Host)
+	})
+}
+
+func TestQueryTags(t *testing.T) {
+	query := &models.Query{Expr: "up", Start: time.Unix(0, 0), End: time.Unix(1234, 0), Step: time.Second, RangeQuery: true}
+
+	t.Run("sends tags of range and instant queries", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		q := *query
+		q.QueryTags = map[string]string{"team": "payments", "dashboard": "abc"}
+
+		res, err := client.QueryRange(context.Background(), &q)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "dashboard=abc,team=payments", doer.LastRequest().Header.Get(QueryTagsHeader))
+
+		res, err = client.QueryInstant(context.Background(), &q)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "dashboard=abc,team=payments", doer.LastRequest().Header.Get(QueryTagsHeader))
+	})
+
+	t.Run("omits header without tags", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		q := *query
+		q.QueryTags = map[string]string{"": "ignored"}
+
+		res, err := client.QueryRange(context.Background(), &q)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.NotContains(t, doer.LastRequest().Header, QueryTagsHeader)
+
+		res, err = client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.NotContains(t, doer.LastRequest().Header, QueryTagsHeader)
+	})
+
+	t.Run("sends tags when falling back to GET", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(byMethod(map[string]*http.Response{
+			http.MethodPost: {StatusCode: http.StatusMethodNotAllowed, Header: http.Header{}},
+			http.MethodGet:  {StatusCode: http.StatusOK, Header: http.Header{}},
+		}))
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090", WithGETFallback(true))
+		q := *query
+		q.QueryTags = map[string]string{"team": "payments"}
+
+		res, err := client.QueryRange(context.Background(), &q)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Len(t, doer.Requests(), 2)
+		require.Equal(t, "team=payments", doer.Requests()[1].Header.Get(QueryTagsHeader))
+	})
+}
+
+func TestResponseHeaders(t *testing.T) {
+	header := http.Header{
+		"Content-Type":          {"application/json"},
+		"X-Ratelimit-Remaining": {"42"},
+		"X-Ratelimit-Reset":     {"30"},
+		"Set-Cookie":            {"session=secret"},
+	}
+
+	t.Run("attaches allowed headers to results", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, header))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithResponseHeaders("x-ratelimit-remaining", "X-Ratelimit-Limit"))
+
+		result, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Equal(t, http.Header{"X-Ratelimit-Remaining": {"42"}}, result.Header)
+	})
+
+	t.Run("attaches no headers by default", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, header))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+
+		result, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Nil(t, result.Header)
+	})
+
+	t.Run("attaches no headers if none are present", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithResponseHeaders("X-Ratelimit-Remaining"))
+
+		result, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Nil(t, result.Header)
+	})
+}
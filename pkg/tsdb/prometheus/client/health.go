@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	// ErrUnauthorized is returned by CheckHealth when Prometheus rejects the credentials with 401 or 403.
+	ErrUnauthorized = errors.New("authentication to Prometheus failed")
+	// ErrUnreachable is returned by CheckHealth when no response could be received from Prometheus.
+	ErrUnreachable = errors.New("failed to connect to Prometheus")
+)
+
+// BuildInfo returns the version information of the Prometheus server.
+func (c *Client) BuildInfo(ctx context.Context) (*http.Response, error) {
+	ctx, span := c.startSpan(ctx, "")
+	defer span.End()
+
+	return c.get(ctx, c.apiEndpoint("status/buildinfo"), nil)
+}
+
+// CheckHealth checks that Prometheus can be queried with the configured settings. Authentication failures wrap
+// ErrUnauthorized and connection failures wrap ErrUnreachable, so callers can show a matching message.
+func (c *Client) CheckHealth(ctx context.Context) error {
+	res, err := c.BuildInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: unexpected status %d", ErrUnauthorized, res.StatusCode)
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	var envelope struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxErrorBodySize)).Decode(&envelope); err != nil {
+		return fmt.Errorf("invalid response from Prometheus: %w", err)
+	}
+	if envelope.Status != "success" {
+		return fmt.Errorf("unexpected response status %q", envelope.Status)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+func TestCheckHealth(t *testing.T) {
+	t.Run("sends buildinfo request", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090")
+		res, err := client.BuildInfo(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.MethodGet, doer.LastRequest().Method)
+		require.Equal(t, "http://localhost:9090/api/v1/status/buildinfo", doer.LastRequest().URL.String())
+	})
+
+	t.Run("returns nil for valid response", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, `{"status":"success","data":{"version":"2.45.0"}}`, nil))
+		require.NoError(t, newTestClient(t, doer, http.MethodGet, "http://localhost:9090").CheckHealth(context.Background()))
+	})
+
+	t.Run("returns ErrUnauthorized for auth failures", func(t *testing.T) {
+		for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+			doer := clienttest.NewFakeDoer(clienttest.Respond(status, "denied", nil))
+			err := newTestClient(t, doer, http.MethodGet, "http://localhost:9090").CheckHealth(context.Background())
+			require.ErrorIs(t, err, ErrUnauthorized)
+		}
+	})
+
+	t.Run("returns ErrUnreachable for connection failures", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})
+		err := newTestClient(t, doer, http.MethodGet, "http://localhost:9090").CheckHealth(context.Background())
+		require.ErrorIs(t, err, ErrUnreachable)
+		require.ErrorContains(t, err, "connection refused")
+	})
+
+	t.Run("returns error for unexpected status", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusNotFound, "404 page not found", nil))
+		err := newTestClient(t, doer, http.MethodGet, "http://localhost:9090").CheckHealth(context.Background())
+		require.EqualError(t, err, "unexpected status 404")
+		require.False(t, errors.Is(err, ErrUnauthorized))
+	})
+
+	t.Run("returns error for invalid body", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, "<html></html>", nil))
+		err := newTestClient(t, doer, http.MethodGet, "http://localhost:9090").CheckHealth(context.Background())
+		require.ErrorContains(t, err, "invalid response from Prometheus")
+	})
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+// WithHedgeURLs sends range queries to the base URL and to the given URLs of replicated Prometheus servers at the
+// same time, and uses the first successful response. The other requests are canceled once it arrives. A response is
+// not successful if the request failed or the server responded with a 5xx status, in which case the client waits for
+// the remaining servers.
+func WithHedgeURLs(urls ...string) Option {
+	return func(c *Client) {
+		c.hedgeURLs = urls
+	}
+}
+
+type hedgeResult struct {
+	index int
+	res   *http.Response
+	err   error
+}
+
+func (r hedgeResult) ok() bool {
+	return r.err == nil && r.res.StatusCode < http.StatusInternalServerError
+}
+
+// hedgeQueryRange races the range query against all replicas and returns the first successful response. If all of
+// them fail, the last result is returned.
+func (c *Client) hedgeQueryRange(ctx context.Context, q *models.Query) (*http.Response, error) {
+	replicas := c.hedgeClients
+
+	// The channel is buffered, so requests that finish after the race is decided do not block their goroutine.
+	results := make(chan hedgeResult, len(replicas))
+	cancels := make([]context.CancelFunc, len(replicas))
+	for i, replica := range replicas {
+		// Every request has its own context, so the winner can keep reading its body after the others are canceled.
+		reqCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		go func(i int, replica *Client) {
+			res, err := replica.QueryRange(reqCtx, q)
+			results <- hedgeResult{index: i, res: res, err: err}
+		}(i, replica)
+	}
+
+	var r hedgeResult
+	for received := 1; ; received++ {
+		r = <-results
+		if r.ok() || received == len(replicas) {
+			// Close the bodies of requests that are still running once they return.
+			go discardHedgeResults(results, len(replicas)-received)
+			break
+		}
+		closeHedgeResult(r, cancels[r.index])
+	}
+
+	for i, cancel := range cancels {
+		if i != r.index {
+			cancel()
+		}
+	}
+
+	if r.err != nil {
+		cancels[r.index]()
+		return nil, r.err
+	}
+
+	r.res.Body = &cancelCloser{ReadCloser: r.res.Body, cancel: cancels[r.index]}
+	return r.res, nil
+}
+
+func discardHedgeResults(results <-chan hedgeResult, n int) {
+	for i := 0; i < n; i++ {
+		closeHedgeResult(<-results, func() {})
+	}
+}
+
+func closeHedgeResult(r hedgeResult, cancel context.CancelFunc) {
+	if r.res != nil {
+		_ = r.res.Body.Close()
+	}
+	cancel()
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+// byHost responds with the responder registered for the host of the request.
+func byHost(responders map[string]clienttest.Responder) clienttest.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		return responders[req.URL.Host](req)
+	}
+}
+
+// cancellations records the hosts of blocked requests that were canceled.
+type cancellations struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (c *cancellations) block(host string) clienttest.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		c.mu.Lock()
+		c.hosts = append(c.hosts, host)
+		c.mu.Unlock()
+		return nil, req.Context().Err()
+	}
+}
+
+func TestHedging(t *testing.T) {
+	t.Run("uses first successful response and cancels the other", func(t *testing.T) {
+		canceled := &cancellations{}
+		doer := clienttest.NewFakeDoer(byHost(map[string]clienttest.Responder{
+			"a:9090": canceled.block("a:9090"),
+			"b:9090": clienttest.Respond(http.StatusOK, "from b", http.Header{}),
+		}))
+		client := newTestClient(t, doer, http.MethodGet, "http://a:9090", WithHedgeURLs("http://b:9090"))
+
+		res, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Equal(t, "from b", string(readBody(t, res)))
+		require.Eventually(t, func() bool {
+			canceled.mu.Lock()
+			defer canceled.mu.Unlock()
+			return len(canceled.hosts) == 1 && canceled.hosts[0] == "a:9090"
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("waits for second server when first fails", func(t *testing.T) {
+		release := make(chan struct{})
+		doer := clienttest.NewFakeDoer(byHost(map[string]clienttest.Responder{
+			"a:9090": func(req *http.Request) (*http.Response, error) {
+				defer close(release)
+				return clienttest.Respond(http.StatusServiceUnavailable, "unavailable", http.Header{})(req)
+			},
+			"b:9090": func(req *http.Request) (*http.Response, error) {
+				<-release
+				return clienttest.Respond(http.StatusOK, "from b", http.Header{})(req)
+			},
+		}))
+		client := newTestClient(t, doer, http.MethodGet, "http://a:9090", WithHedgeURLs("http://b:9090"))
+
+		res, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "from b", string(readBody(t, res)))
+	})
+
+	t.Run("returns last failure when all servers fail", func(t *testing.T) {
+		release := make(chan struct{})
+		doer := clienttest.NewFakeDoer(byHost(map[string]clienttest.Responder{
+			"a:9090": func(req *http.Request) (*http.Response, error) {
+				defer close(release)
+				return clienttest.Respond(http.StatusServiceUnavailable, "unavailable", http.Header{})(req)
+			},
+			"b:9090": func(req *http.Request) (*http.Response, error) {
+				<-release
+				return nil, errors.New("connection refused")
+			},
+		}))
+		client := newTestClient(t, doer, http.MethodGet, "http://a:9090", WithHedgeURLs("http://b:9090"))
+
+		_, err := client.QueryRange(context.Background(), rangeQuery)
+		require.ErrorContains(t, err, "connection refused")
+	})
+
+	t.Run("keeps winner context until body is closed", func(t *testing.T) {
+		var winnerReq *http.Request
+		canceled := &cancellations{}
+		doer := clienttest.NewFakeDoer(byHost(map[string]clienttest.Responder{
+			"a:9090": func(req *http.Request) (*http.Response, error) {
+				winnerReq = req
+				return clienttest.Respond(http.StatusOK, "from a", http.Header{})(req)
+			},
+			"b:9090": canceled.block("b:9090"),
+		}))
+		client := newTestClient(t, doer, http.MethodGet, "http://a:9090", WithHedgeURLs("http://b:9090"))
+
+		res, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.NoError(t, winnerReq.Context().Err())
+		require.NoError(t, res.Body.Close())
+		require.ErrorIs(t, winnerReq.Context().Err(), context.Canceled)
+	})
+}
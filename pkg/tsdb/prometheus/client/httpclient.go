@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// WithMaxIdleConnsPerHost sets how many idle connections to Prometheus are kept open for reuse. It only applies if
+// NewClient is called without a doer and creates its own HTTP client.
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(c *Client) {
+		c.transport.maxIdleConnsPerHost = n
+	}
+}
+
+// WithIdleConnTimeout sets how long idle connections to Prometheus are kept open. It only applies if NewClient is
+// called without a doer and creates its own HTTP client.
+func WithIdleConnTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.transport.idleConnTimeout = timeout
+	}
+}
+
+// WithDialTimeout sets how long connecting to Prometheus may take, so unreachable servers fail fast even if queries
+// may take much longer. It only applies if NewClient is called without a doer and creates its own HTTP client. Zero
+// keeps the default of 30s.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.transport.dialTimeout = timeout
+	}
+}
+
+// WithTransport sets the round tripper of the HTTP client created by NewClient, e.g. to configure TLS or a proxy. It
+// is ignored with a warning if NewClient is called with a doer. The connection options do not apply to it.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport.roundTripper = rt
+	}
+}
+
+// WithH2C makes the HTTP client created by NewClient talk HTTP/2 with prior knowledge over cleartext connections, for
+// backends that only accept HTTP/2 without TLS. https URLs are not affected. The connection options only apply to
+// https. It is ignored if NewClient is called with a doer or WithTransport is used.
+func WithH2C(enabled bool) Option {
+	return func(c *Client) {
+		c.transport.h2c = enabled
+	}
+}
+
+// WithMaxRedirects makes the HTTP client created by NewClient follow up to n redirects. By default redirects are not
+// followed, so a gateway redirecting to a login page fails queries with ErrRedirected instead of returning the page.
+// It is ignored if NewClient is called with a doer.
+func WithMaxRedirects(n int) Option {
+	return func(c *Client) {
+		c.transport.maxRedirects = n
+	}
+}
+
+// defaultDialTimeout is the dial timeout of http.DefaultTransport.
+const defaultDialTimeout = 30 * time.Second
+
+// transportOptions configure the transport of the HTTP client created by NewClient. Zero values keep the defaults of
+// http.DefaultTransport.
+type transportOptions struct {
+	roundTripper        http.RoundTripper
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	dialTimeout         time.Duration
+	h2c                 bool
+	maxRedirects        int
+}
+
+// newHTTPClient creates the HTTP client used when NewClient is called without a doer.
+func newHTTPClient(opts transportOptions) *http.Client {
+	if opts.roundTripper != nil {
+		return &http.Client{Transport: opts.roundTripper, CheckRedirect: checkRedirect(opts.maxRedirects)}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.maxIdleConnsPerHost
+	}
+	if opts.idleConnTimeout > 0 {
+		transport.IdleConnTimeout = opts.idleConnTimeout
+	}
+	dialer := newDialer(opts)
+	if opts.dialTimeout > 0 {
+		transport.DialContext = dialer.DialContext
+	}
+	var rt http.RoundTripper = transport
+	if opts.h2c {
+		rt = newH2CTransport(transport, dialer)
+	}
+	return &http.Client{Transport: rt, CheckRedirect: checkRedirect(opts.maxRedirects)}
+}
+
+// newDialer creates the dialer for connections to Prometheus, with the same keep-alive as http.DefaultTransport.
+func newDialer(opts transportOptions) *net.Dialer {
+	timeout := opts.dialTimeout
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	return &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+}
+
+// checkRedirect returns a redirect policy following up to maxRedirects redirects. Further redirects are not followed
+// and their response is returned.
+func checkRedirect(maxRedirects int) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+}
+
+// h2cTransport sends requests to http URLs over HTTP/2 with prior knowledge. Other requests, in particular https, use
+// the regular transport.
+type h2cTransport struct {
+	h2c  *http2.Transport
+	next http.RoundTripper
+}
+
+func newH2CTransport(next http.RoundTripper, dialer *net.Dialer) *h2cTransport {
+	return &h2cTransport{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			// The transport dials TLS for all connections, AllowHTTP only lets it accept http URLs.
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+		next: next,
+	}
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.next.RoundTrip(req)
+}
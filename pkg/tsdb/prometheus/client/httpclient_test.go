@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestHTTPClientOptions(t *testing.T) {
+	t.Run("configures transport of own HTTP client", func(t *testing.T) {
+		client := newTestClient(t, nil, http.MethodGet, "http://localhost:9090", WithMaxIdleConnsPerHost(50), WithIdleConnTimeout(5*time.Minute))
+
+		httpClient, ok := client.doer.(*http.Client)
+		require.True(t, ok)
+		transport := httpClient.Transport.(*http.Transport)
+		require.Equal(t, 50, transport.MaxIdleConnsPerHost)
+		require.Equal(t, 5*time.Minute, transport.IdleConnTimeout)
+	})
+
+	t.Run("keeps default transport settings", func(t *testing.T) {
+		client := newTestClient(t, nil, http.MethodGet, "http://localhost:9090")
+
+		transport := client.doer.(*http.Client).Transport.(*http.Transport)
+		defaults := http.DefaultTransport.(*http.Transport)
+		require.Equal(t, defaults.MaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+		require.Equal(t, defaults.IdleConnTimeout, transport.IdleConnTimeout)
+		require.NotSame(t, defaults, transport)
+	})
+
+	t.Run("configures dial timeout", func(t *testing.T) {
+		client := newTestClient(t, nil, http.MethodGet, "http://localhost:9090", WithDialTimeout(3*time.Second))
+
+		transport := client.doer.(*http.Client).Transport.(*http.Transport)
+		require.NotNil(t, transport.DialContext)
+		require.Equal(t, 3*time.Second, newDialer(client.transport).Timeout)
+		require.Equal(t, defaultDialTimeout, newDialer(transportOptions{}).Timeout)
+	})
+
+	t.Run("fails to connect after the dial timeout", func(t *testing.T) {
+		client := newTestClient(t, nil, http.MethodGet, "http://localhost:9090", WithDialTimeout(time.Nanosecond))
+
+		_, err := client.Alerts(context.Background())
+		var netErr net.Error
+		require.ErrorAs(t, err, &netErr)
+		require.True(t, netErr.Timeout())
+	})
+
+	t.Run("ignores options with custom doer", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithMaxIdleConnsPerHost(50))
+		require.Same(t, doer, client.doer)
+	})
+
+	t.Run("uses custom transport", func(t *testing.T) {
+		var called bool
+		rt := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			called = true
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+		})
+		client := newTestClient(t, nil, http.MethodGet, "http://localhost:9090", WithTransport(rt))
+
+		res, err := client.Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.True(t, called)
+	})
+
+	t.Run("prefers doer over transport", func(t *testing.T) {
+		logger := &recordingLogger{}
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithLogger(logger), WithTransport(http.DefaultTransport))
+		require.Same(t, doer, client.doer)
+		require.Equal(t, []string{"Both a doer and a transport are configured, using the doer"}, logger.warnings)
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestH2C(t *testing.T) {
+	var proto string
+	srv := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":[]}`))
+	}), &http2.Server{}))
+	defer srv.Close()
+
+	t.Run("uses HTTP/2 with prior knowledge when enabled", func(t *testing.T) {
+		client := newTestClient(t, nil, http.MethodGet, srv.URL, WithH2C(true))
+
+		res, err := client.Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "HTTP/2.0", proto)
+	})
+
+	t.Run("uses HTTP/1.1 by default", func(t *testing.T) {
+		client := newTestClient(t, nil, http.MethodGet, srv.URL)
+
+		res, err := client.Alerts(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "HTTP/1.1", proto)
+	})
+
+	t.Run("is ignored with custom transport", func(t *testing.T) {
+		client := newTestClient(t, nil, http.MethodGet, srv.URL, WithH2C(true), WithTransport(http.DefaultTransport))
+		require.Same(t, http.DefaultTransport, client.doer.(*http.Client).Transport)
+	})
+}
+
+func TestRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/query":
+			http.Redirect(w, r, "/login", http.StatusFound)
+		case "/api/v1/labels":
+			http.Redirect(w, r, "/a/api/v1/labels", http.StatusFound)
+		case "/a/api/v1/labels":
+			http.Redirect(w, r, "/b/api/v1/labels", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"success","data":[]}`))
+		}
+	}))
+	defer srv.Close()
+	query := &models.Query{Expr: "up", End: time.Unix(1234, 0)}
+
+	t.Run("does not follow redirects by default", func(t *testing.T) {
+		client := newTestClient(t, nil, http.MethodGet, srv.URL)
+
+		_, err := client.QueryInstant(context.Background(), query)
+		require.ErrorIs(t, err, ErrRedirected)
+		require.ErrorContains(t, err, "/login")
+
+		res, err := client.LabelNames(context.Background(), nil, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusFound, res.StatusCode)
+	})
+
+	t.Run("follows limited number of redirects", func(t *testing.T) {
+		res, err := newTestClient(t, nil, http.MethodGet, srv.URL, WithMaxRedirects(2)).LabelNames(context.Background(), nil, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, err = newTestClient(t, nil, http.MethodGet, srv.URL, WithMaxRedirects(1)).LabelNames(context.Background(), nil, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusFound, res.StatusCode)
+	})
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// truncatedWarning is the warning Prometheus adds when a limit cut off the returned values.
+const truncatedWarning = "results truncated due to limit"
+
+// LabelValuesResult is a parsed label values response.
+type LabelValuesResult struct {
+	Values []string
+	// Warnings are the warnings of the response, e.g. about truncation by the limit.
+	Warnings []string
+	// Truncated is set if the server reported that the limit cut off values, so more values exist than were returned.
+	Truncated bool
+}
+
+// LabelValuesResult returns the values of the given label like LabelValues and also the warnings of the response.
+func (c *Client) LabelValuesResult(ctx context.Context, label string, matchers []string, start, end time.Time, limit int) (*LabelValuesResult, error) {
+	res, err := c.LabelValues(ctx, label, matchers, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+	res, err = checkError(res)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	var envelope struct {
+		Status   string   `json:"status"`
+		Data     []string `json:"data"`
+		Warnings []string `json:"warnings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("invalid label values response with status %d: %w", res.StatusCode, err)
+	}
+	if envelope.Status != "success" {
+		return nil, fmt.Errorf("unexpected response status %q", envelope.Status)
+	}
+
+	result := &LabelValuesResult{Values: envelope.Data, Warnings: envelope.Warnings}
+	if result.Values == nil {
+		result.Values = []string{}
+	}
+	for _, w := range envelope.Warnings {
+		if strings.Contains(w, truncatedWarning) {
+			result.Truncated = true
+		}
+	}
+	return result, nil
+}
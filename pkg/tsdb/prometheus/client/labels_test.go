@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+func TestLabelValuesLimit(t *testing.T) {
+	t.Run("sends positive limit", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		res, err := client.LabelValues(context.Background(), "job", []string{"up"}, time.Time{}, time.Time{}, 100)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "http://localhost:9090/api/v1/label/job/values?limit=100&match%5B%5D=up", doer.LastRequest().URL.String())
+	})
+
+	t.Run("omits limit that is not positive", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		for _, limit := range []int{0, -1} {
+			res, err := client.LabelValues(context.Background(), "job", nil, time.Time{}, time.Time{}, limit)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Empty(t, doer.LastRequest().URL.RawQuery)
+		}
+	})
+
+	t.Run("sends limit in JSON body", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithJSONMatchers(true))
+		res, err := client.LabelValues(context.Background(), "job", []string{"up"}, time.Time{}, time.Time{}, 100)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		body, err := io.ReadAll(doer.LastRequest().Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"matchers":["up"],"limit":100}`, string(body))
+	})
+}
+
+func TestLabelValuesResult(t *testing.T) {
+	t.Run("reports truncation warning", func(t *testing.T) {
+		body := `{"status":"success","data":["a","b"],"warnings":["results truncated due to limit"]}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		result, err := client.LabelValuesResult(context.Background(), "job", nil, time.Time{}, time.Time{}, 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b"}, result.Values)
+		require.Equal(t, []string{"results truncated due to limit"}, result.Warnings)
+		require.True(t, result.Truncated)
+	})
+
+	t.Run("keeps other warnings", func(t *testing.T) {
+		body := `{"status":"success","data":["a"],"warnings":["store unavailable"]}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		result, err := client.LabelValuesResult(context.Background(), "job", nil, time.Time{}, time.Time{}, 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"store unavailable"}, result.Warnings)
+		require.False(t, result.Truncated)
+	})
+
+	t.Run("returns empty values", func(t *testing.T) {
+		body := `{"status":"success","data":null}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		result, err := client.LabelValuesResult(context.Background(), "job", nil, time.Time{}, time.Time{}, 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{}, result.Values)
+		require.Empty(t, result.Warnings)
+	})
+
+	t.Run("returns Prometheus errors", func(t *testing.T) {
+		body := `{"status":"error","errorType":"bad_data","error":"invalid label name"}`
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusBadRequest, body, nil)), http.MethodGet, "http://localhost:9090")
+
+		_, err := client.LabelValuesResult(context.Background(), "job", nil, time.Time{}, time.Time{}, 0)
+		var promErr *PrometheusError
+		require.True(t, errors.As(err, &promErr))
+		require.Equal(t, ErrorTypeBadData, promErr.Type)
+	})
+
+	t.Run("rejects invalid responses", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusBadGateway, "<html>502 Bad Gateway</html>", nil)), http.MethodGet, "http://localhost:9090")
+
+		_, err := client.LabelValuesResult(context.Background(), "job", nil, time.Time{}, time.Time{}, 0)
+		require.ErrorContains(t, err, "502 Bad Gateway")
+	})
+}
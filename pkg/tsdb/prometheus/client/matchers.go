@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithJSONMatchers makes Series, LabelNames and LabelValues send their matchers and time range as a JSON body like
+// {"matchers":["up"],"start":1655271408,"end":1655293008} with POST, for stores that accept JSON. By default they are
+// form encoded like for Prometheus.
+func WithJSONMatchers(enabled bool) Option {
+	return func(c *Client) {
+		c.jsonMatchers = enabled
+	}
+}
+
+// matcherBody is the JSON body of series and label requests. Times are sent as exact decimal seconds.
+type matcherBody struct {
+	Matchers []string    `json:"matchers"`
+	Start    json.Number `json:"start,omitempty"`
+	End      json.Number `json:"end,omitempty"`
+	Limit    int         `json:"limit,omitempty"`
+}
+
+// createMatcherRequest creates a request for an endpoint that looks up series by matchers. Zero start or end times
+// and limits that are not positive are not sent, so the server defaults apply.
+func (c *Client) createMatcherRequest(ctx context.Context, endpoint string, matchers []string, start, end time.Time, limit int) (*http.Request, error) {
+	if !c.jsonMatchers {
+		v := matcherValues(matchers, start, end)
+		if limit > 0 {
+			v.Set("limit", strconv.Itoa(limit))
+		}
+		return c.createValuesRequest(ctx, "", endpoint, v)
+	}
+
+	body := matcherBody{Matchers: matchers}
+	if limit > 0 {
+		body.Limit = limit
+	}
+	if body.Matchers == nil {
+		body.Matchers = []string{}
+	}
+	if !start.IsZero() {
+		body.Start = json.Number(formatTime(start))
+	}
+	if !end.IsZero() {
+		body.End = json.Number(formatTime(end))
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := c.createUrl(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req, err := createRequest(ctx, http.MethodPost, u, b)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
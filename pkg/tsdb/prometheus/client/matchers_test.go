@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+func TestJSONMatchers(t *testing.T) {
+	start, end := time.Unix(1655271408, 0), time.Unix(1655293008, 500*int64(time.Millisecond))
+
+	t.Run("sends matchers as JSON", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithJSONMatchers(true))
+
+		calls := map[string]func() (*http.Response, error){
+			"/api/v1/series": func() (*http.Response, error) {
+				return client.Series(context.Background(), []string{"up", `{"my.metric"}`}, start, end)
+			},
+			"/api/v1/labels": func() (*http.Response, error) {
+				return client.LabelNames(context.Background(), []string{"up", `{"my.metric"}`}, start, end)
+			},
+			"/api/v1/label/job/values": func() (*http.Response, error) {
+				return client.LabelValues(context.Background(), "job", []string{"up", `{"my.metric"}`}, start, end, 0)
+			},
+		}
+		for path, call := range calls {
+			res, err := call()
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, http.MethodPost, doer.LastRequest().Method)
+			require.Equal(t, path, doer.LastRequest().URL.Path)
+			require.Empty(t, doer.LastRequest().URL.RawQuery)
+			require.Equal(t, "application/json", doer.LastRequest().Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(doer.LastRequest().Body)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"matchers":["up","{\"my.metric\"}"],"start":1655271408,"end":1655293008.5}`, string(body))
+		}
+	})
+
+	t.Run("omits zero times", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithJSONMatchers(true))
+		res, err := client.LabelNames(context.Background(), nil, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		body, err := io.ReadAll(doer.LastRequest().Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"matchers":[]}`, string(body))
+	})
+
+	t.Run("form encodes matchers by default", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090")
+		res, err := client.Series(context.Background(), []string{"up"}, start, end)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "application/x-www-form-urlencoded", doer.LastRequest().Header.Get("Content-Type"))
+	})
+}
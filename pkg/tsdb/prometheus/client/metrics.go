@@ -0,0 +1,99 @@
+package client
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics collects metrics about the requests sent by clients. One Metrics can be shared by many clients and has to
+// be registered by the caller, e.g. prometheus.MustRegister(metrics).
+type Metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+	size     *prometheus.HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grafana",
+			Name:      "prometheus_client_requests_total",
+			Help:      "The total amount of requests sent to Prometheus",
+		}, []string{"endpoint", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "grafana",
+			Name:      "prometheus_client_request_duration_seconds",
+			Help:      "Duration of requests sent to Prometheus",
+			Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"endpoint"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "grafana",
+			Name:      "prometheus_client_requests_in_flight",
+			Help:      "The number of requests to Prometheus that are waiting for a response",
+		}),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "grafana",
+			Name:      "prometheus_client_response_size_bytes",
+			Help:      "Decompressed size of the responses read from Prometheus",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+		}, []string{"endpoint"}),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+	m.inFlight.Describe(ch)
+	m.size.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+	m.inFlight.Collect(ch)
+	m.size.Collect(ch)
+}
+
+// WithMetrics records metrics of every request sent to Prometheus, including each retry attempt.
+func WithMetrics(m *Metrics) Option {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+type instrumentedDoer struct {
+	next    doer
+	metrics *Metrics
+	now     func() time.Time
+}
+
+func (d *instrumentedDoer) Do(req *http.Request) (*http.Response, error) {
+	endpoint := metricsEndpoint(req.URL.Path)
+
+	d.metrics.inFlight.Inc()
+	defer d.metrics.inFlight.Dec()
+
+	start := d.now()
+	res, err := d.next.Do(req)
+	d.metrics.duration.WithLabelValues(endpoint).Observe(d.now().Sub(start).Seconds())
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	d.metrics.requests.WithLabelValues(endpoint, status).Inc()
+
+	return res, err
+}
+
+var labelValuesPath = regexp.MustCompile(`/label/[^/]+/values$`)
+
+// metricsEndpoint removes label names from the path to keep the cardinality of the endpoint label low.
+func metricsEndpoint(p string) string {
+	return labelValuesPath.ReplaceAllString(p, "/label/:name/values")
+}
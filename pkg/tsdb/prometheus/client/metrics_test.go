@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("counts requests by endpoint and status", func(t *testing.T) {
+		metrics := NewMetrics()
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Status(http.StatusOK)), http.MethodGet, "http://localhost:9090", WithMetrics(metrics))
+
+		_, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		_, err = client.LabelValues(context.Background(), "job", nil, time.Time{}, time.Time{}, 0)
+		require.NoError(t, err)
+
+		expected := `
+# HELP grafana_prometheus_client_requests_total The total amount of requests sent to Prometheus
+# TYPE grafana_prometheus_client_requests_total counter
+grafana_prometheus_client_requests_total{endpoint="/api/v1/label/:name/values",status="200"} 1
+grafana_prometheus_client_requests_total{endpoint="/api/v1/query_range",status="200"} 1
+`
+		require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(expected), "grafana_prometheus_client_requests_total"))
+		require.Equal(t, 2, testutil.CollectAndCount(metrics, "grafana_prometheus_client_request_duration_seconds"))
+		require.Equal(t, float64(0), testutil.ToFloat64(metrics.inFlight))
+	})
+
+	t.Run("counts failed requests", func(t *testing.T) {
+		metrics := NewMetrics()
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Fail(errors.New("connection refused"))), http.MethodGet, "http://localhost:9090", WithMetrics(metrics))
+
+		_, err := client.QueryRange(context.Background(), rangeQuery)
+		require.Error(t, err)
+		require.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("/api/v1/query_range", "error")))
+	})
+
+	t.Run("counts in-flight requests", func(t *testing.T) {
+		metrics := NewMetrics()
+		var inFlight float64
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			inFlight = testutil.ToFloat64(metrics.inFlight)
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithMetrics(metrics))
+
+		_, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Equal(t, float64(1), inFlight)
+		require.Equal(t, float64(0), testutil.ToFloat64(metrics.inFlight))
+	})
+
+	t.Run("can be registered", func(t *testing.T) {
+		require.NoError(t, prometheus.NewRegistry().Register(NewMetrics()))
+	})
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestLog describes a finished request. It never contains bodies.
+type RequestLog struct {
+	Method   string
+	Endpoint string
+	Status   int
+	Duration time.Duration
+	// Header is a copy of the request headers, with credentials redacted.
+	Header http.Header
+	// Err is the error of the request, if it failed without a response.
+	Err error
+}
+
+// redactedHeaders are replaced in RequestLog.Header, as they carry credentials.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// WithRequestLogger sets a function that is called after every request with its metadata, e.g. for audit logs.
+func WithRequestLogger(logger func(RequestLog)) Option {
+	return func(c *Client) {
+		c.requestLogger = logger
+	}
+}
+
+func (c *Client) logRequest(req *http.Request, res *http.Response, err error, duration time.Duration) {
+	if c.requestLogger == nil {
+		return
+	}
+
+	header := req.Header.Clone()
+	for _, name := range redactedHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, "[REDACTED]")
+		}
+	}
+
+	entry := RequestLog{Method: req.Method, Endpoint: req.URL.Path, Duration: duration, Header: header, Err: err}
+	if res != nil {
+		entry.Status = res.StatusCode
+	}
+	c.requestLogger(entry)
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+func TestRequestLogger(t *testing.T) {
+	t.Run("logs request metadata with redacted credentials", func(t *testing.T) {
+		var logs []RequestLog
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090",
+			WithBearerToken("secret"), WithRequestLogger(func(l RequestLog) {
+				logs = append(logs, l)
+			}))
+
+		res, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		require.Len(t, logs, 1)
+		require.Equal(t, http.MethodGet, logs[0].Method)
+		require.Equal(t, "/api/v1/query_range", logs[0].Endpoint)
+		require.Equal(t, http.StatusOK, logs[0].Status)
+		require.Equal(t, "[REDACTED]", logs[0].Header.Get("Authorization"))
+		require.Equal(t, defaultUserAgent, logs[0].Header.Get("User-Agent"))
+		require.NoError(t, logs[0].Err)
+	})
+
+	t.Run("logs errors", func(t *testing.T) {
+		var logs []RequestLog
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithRequestLogger(func(l RequestLog) {
+			logs = append(logs, l)
+		}))
+
+		_, err := client.QueryRange(context.Background(), rangeQuery)
+		require.Error(t, err)
+		require.Len(t, logs, 1)
+		require.Zero(t, logs[0].Status)
+		require.EqualError(t, logs[0].Err, "connection refused")
+		require.Empty(t, logs[0].Header.Get("Authorization"))
+	})
+}
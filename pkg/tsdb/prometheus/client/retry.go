@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AttemptsHeader is set on responses of a client with a retry policy to the number of attempts it took to get the
+// response, so callers can tell a flaky backend from a stable one even if the final response succeeded.
+const AttemptsHeader = "X-Grafana-Attempts"
+
+// RetryPolicy configures how failed requests are retried. Idempotent GET requests are retried on retryable status
+// codes, 502, 503 and 504 by default, and on network errors. POST requests are only retried when the connection
+// failed before any part of the request body was sent.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It doubles on every following retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. Zero means no cap. The Retry-After header of a response replaces the
+	// delay, responses that ask to wait longer than MaxDelay are not retried.
+	MaxDelay time.Duration
+	// Jitter is the fraction of the delay, between 0 and 1, that is randomized to spread out retries.
+	Jitter float64
+	// RetryableStatusCodes replaces the status codes that are retried, e.g. to add 429. Nil keeps the defaults, an
+	// empty list only retries network errors.
+	RetryableStatusCodes []int
+}
+
+// WithRetryPolicy enables retries of failed requests.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = &p
+	}
+}
+
+var defaultRetryableStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+type retryDoer struct {
+	next      doer
+	policy    RetryPolicy
+	retryable map[int]bool
+	now       func() time.Time
+}
+
+func newRetryDoer(next doer, policy RetryPolicy) *retryDoer {
+	codes := policy.RetryableStatusCodes
+	if codes == nil {
+		codes = defaultRetryableStatusCodes
+	}
+	retryable := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		retryable[code] = true
+	}
+	return &retryDoer{next: next, policy: policy, retryable: retryable, now: time.Now}
+}
+
+func (d *retryDoer) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	for attempt := 1; ; attempt++ {
+		attemptReq, body, err := newAttempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := d.next.Do(attemptReq)
+		if attempt >= d.policy.MaxAttempts || !d.shouldRetry(ctx, req, idempotent, res, err, body) {
+			recordAttempts(ctx, res, attempt)
+			return res, err
+		}
+
+		delay := d.backoff(attempt)
+		if res != nil {
+			if after, ok := retryAfter(res.Header.Get("Retry-After"), d.now()); ok {
+				if d.policy.MaxDelay > 0 && after > d.policy.MaxDelay {
+					// Retrying earlier than the server asked for would most likely fail again.
+					recordAttempts(ctx, res, attempt)
+					return res, err
+				}
+				delay = after
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// There is no point in waiting if the request would be cancelled before the next attempt.
+			recordAttempts(ctx, res, attempt)
+			return res, err
+		}
+
+		if res != nil {
+			drainAndClose(res.Body)
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			recordAttempts(ctx, nil, attempt)
+			return nil, err
+		}
+	}
+}
+
+// recordAttempts adds the number of attempts to the response and to the span of the request, if there is one.
+func recordAttempts(ctx context.Context, res *http.Response, attempts int) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.attempts", attempts))
+	if res == nil {
+		return
+	}
+	if res.Header == nil {
+		res.Header = http.Header{}
+	}
+	res.Header.Set(AttemptsHeader, strconv.Itoa(attempts))
+}
+
+// newAttempt creates the request for the given attempt. Retries get a fresh copy of the body, the body of every
+// attempt is tracked so we know whether any of it was sent.
+func newAttempt(req *http.Request, attempt int) (*http.Request, *trackingBody, error) {
+	attemptReq := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return attemptReq, nil, nil
+	}
+
+	rc := req.Body
+	if attempt > 1 {
+		var err error
+		rc, err = req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	body := &trackingBody{ReadCloser: rc}
+	attemptReq.Body = body
+	return attemptReq, body, nil
+}
+
+func (d *retryDoer) shouldRetry(ctx context.Context, req *http.Request, idempotent bool, res *http.Response, err error, body *trackingBody) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// Without GetBody we are not able to send the same body again.
+	if body != nil && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		if idempotent {
+			return true
+		}
+		// Non idempotent requests are only safe to retry when the server could not have seen any part of it.
+		return body == nil || !body.read
+	}
+
+	return idempotent && d.retryable[res.StatusCode]
+}
+
+func (d *retryDoer) backoff(attempt int) time.Duration {
+	delay := float64(d.policy.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if d.policy.MaxDelay > 0 && delay > float64(d.policy.MaxDelay) {
+		delay = float64(d.policy.MaxDelay)
+	}
+
+	if d.policy.Jitter > 0 {
+		jitter := math.Min(d.policy.Jitter, 1)
+		delay -= delay * jitter * rand.Float64()
+	}
+
+	return time.Duration(delay)
+}
+
+// retryAfter parses the value of a Retry-After header, which is either a number of seconds or an HTTP date. Dates are
+// relative to now.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func drainAndClose(body io.ReadCloser) {
+	if body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 4096))
+	_ = body.Close()
+}
+
+// trackingBody records whether the transport started reading the request body.
+type trackingBody struct {
+	io.ReadCloser
+	read bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.read = true
+	}
+	return n, err
+}
@@ -0,0 +1,299 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+var testPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestRetryDoer(t *testing.T) {
+	t.Run("retries GET on retryable status", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable),
+			clienttest.Status(http.StatusBadGateway),
+			clienttest.Status(http.StatusOK),
+		))
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		res, err := newRetryDoer(next, testPolicy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, 3, next.Calls())
+		require.Equal(t, "3", res.Header.Get(AttemptsHeader))
+	})
+
+	t.Run("records a single attempt", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusOK)))
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		res, err := newRetryDoer(next, testPolicy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, "1", res.Header.Get(AttemptsHeader))
+	})
+
+	t.Run("returns last response when attempts are exhausted", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusGatewayTimeout),
+			clienttest.Status(http.StatusGatewayTimeout),
+			clienttest.Status(http.StatusGatewayTimeout),
+		))
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		res, err := newRetryDoer(next, testPolicy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+		require.Equal(t, 3, next.Calls())
+		require.Equal(t, "3", res.Header.Get(AttemptsHeader))
+	})
+
+	t.Run("does not retry GET on bad request", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusBadRequest)))
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		res, err := newRetryDoer(next, testPolicy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, 1, next.Calls())
+	})
+
+	t.Run("does not retry POST on retryable status", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable)))
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:9090/api/v1/query", strings.NewReader("query=up"))
+		require.NoError(t, err)
+
+		res, err := newRetryDoer(next, testPolicy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		require.Equal(t, 1, next.Calls())
+	})
+
+	t.Run("retries POST when connection failed before the body was sent", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Fail(errors.New("dial tcp: connection refused")), clienttest.Status(http.StatusOK)))
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:9090/api/v1/query", strings.NewReader("query=up"))
+		require.NoError(t, err)
+
+		res, err := newRetryDoer(next, testPolicy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, 2, next.Calls())
+		require.Equal(t, []string{"", "query=up"}, next.Bodies())
+	})
+
+	t.Run("does not retry POST after the body was consumed", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			_, _ = io.ReadAll(req.Body)
+			return nil, errors.New("connection reset by peer")
+		})
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:9090/api/v1/query", strings.NewReader("query=up"))
+		require.NoError(t, err)
+
+		_, err = newRetryDoer(next, testPolicy).Do(req)
+		require.Error(t, err)
+		require.Equal(t, 1, next.Calls())
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable),
+			clienttest.Status(http.StatusOK),
+		))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		res, err := newRetryDoer(next, testPolicy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		require.Equal(t, 1, next.Calls())
+	})
+
+	t.Run("does not wait past the context deadline", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable),
+			clienttest.Status(http.StatusOK),
+		))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		d := newRetryDoer(next, RetryPolicy{MaxAttempts: 2, BaseDelay: time.Minute})
+		res, err := d.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		require.Equal(t, 1, next.Calls())
+	})
+
+	t.Run("retries configured status codes", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusTooManyRequests),
+			clienttest.Status(http.StatusOK),
+		))
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		policy := testPolicy
+		policy.RetryableStatusCodes = []int{http.StatusTooManyRequests}
+		res, err := newRetryDoer(next, policy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, 2, next.Calls())
+	})
+
+	t.Run("does not retry status codes missing from the configured ones", func(t *testing.T) {
+		for _, codes := range [][]int{{http.StatusTooManyRequests}, {}} {
+			next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable)))
+			req, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+			require.NoError(t, err)
+
+			policy := testPolicy
+			policy.RetryableStatusCodes = codes
+			res, err := newRetryDoer(next, policy).Do(req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+			require.Equal(t, 1, next.Calls())
+		}
+	})
+
+	t.Run("waits for Retry-After", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(
+			func(req *http.Request) (*http.Response, error) {
+				res, err := clienttest.Status(http.StatusTooManyRequests)(req)
+				res.Header.Set("Retry-After", "1")
+				return res, err
+			},
+			clienttest.Status(http.StatusOK),
+		))
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, RetryableStatusCodes: []int{http.StatusTooManyRequests}}
+		start := time.Now()
+		res, err := newRetryDoer(next, policy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.GreaterOrEqual(t, time.Since(start), time.Second)
+	})
+
+	t.Run("does not retry when Retry-After exceeds max delay", func(t *testing.T) {
+		next := clienttest.NewFakeDoer(clienttest.Sequence(
+			func(req *http.Request) (*http.Response, error) {
+				res, err := clienttest.Status(http.StatusServiceUnavailable)(req)
+				res.Header.Set("Retry-After", "120")
+				return res, err
+			},
+		))
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		res, err := newRetryDoer(next, testPolicy).Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		require.Equal(t, 1, next.Calls())
+	})
+
+	t.Run("parses Retry-After", func(t *testing.T) {
+		now := time.Unix(1641889530, 0)
+		d, ok := retryAfter("30", now)
+		require.True(t, ok)
+		require.Equal(t, 30*time.Second, d)
+
+		d, ok = retryAfter(now.Add(time.Minute).UTC().Format(http.TimeFormat), now)
+		require.True(t, ok)
+		require.Equal(t, time.Minute, d)
+
+		d, ok = retryAfter(now.Add(-time.Minute).UTC().Format(http.TimeFormat), now)
+		require.True(t, ok)
+		require.Zero(t, d)
+
+		for _, invalid := range []string{"", "-1", "soon"} {
+			_, ok = retryAfter(invalid, now)
+			require.False(t, ok, invalid)
+		}
+	})
+
+	t.Run("backoff is capped by max delay", func(t *testing.T) {
+		d := newRetryDoer(nil, RetryPolicy{BaseDelay: time.Second, MaxDelay: 3 * time.Second})
+		require.Equal(t, time.Second, d.backoff(1))
+		require.Equal(t, 2*time.Second, d.backoff(2))
+		require.Equal(t, 3*time.Second, d.backoff(3))
+	})
+}
+
+func TestRequestBodyBuffering(t *testing.T) {
+	query := &models.Query{
+		Expr:  "sum(up{job=~\"" + strings.Repeat("a", 200) + "\"})",
+		Start: time.Unix(0, 0),
+		End:   time.Unix(1234, 0),
+		Step:  time.Second,
+	}
+
+	t.Run("sends the same body on every attempt", func(t *testing.T) {
+		for _, threshold := range []int{0, 10} {
+			doer := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Fail(errors.New("dial tcp: connection refused")), clienttest.Status(http.StatusOK)))
+			client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090", WithRetryPolicy(testPolicy), WithRequestCompression(threshold))
+
+			res, err := client.QueryRange(context.Background(), query)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, 2, doer.Calls())
+			require.Len(t, doer.Bodies(), 2)
+			require.NotEmpty(t, doer.Bodies()[1])
+		}
+	})
+
+	t.Run("returns identical bodies from GetBody", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090")
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		body, err := io.ReadAll(doer.LastRequest().Body)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(body)), doer.LastRequest().ContentLength)
+		for i := 0; i < 2; i++ {
+			rc, err := doer.LastRequest().GetBody()
+			require.NoError(t, err)
+			again, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			require.Equal(t, body, again)
+		}
+	})
+
+	t.Run("sends the body again on redirects", func(t *testing.T) {
+		var bodies []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			bodies = append(bodies, string(b))
+			if r.URL.Path == "/old/api/v1/query_range" {
+				http.Redirect(w, r, "/new/api/v1/query_range", http.StatusTemporaryRedirect)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		}))
+		defer srv.Close()
+
+		client := newTestClient(t, nil, http.MethodPost, srv.URL+"/old", WithMaxRedirects(1))
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Len(t, bodies, 2)
+		require.NotEmpty(t, bodies[0])
+		require.Equal(t, bodies[0], bodies[1])
+	})
+}
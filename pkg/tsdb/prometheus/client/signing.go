@@ -0,0 +1,29 @@
+package client
+
+import (
+	"net/http"
+)
+
+// RequestSigner signs a request right before it is sent, e.g. with AWS SigV4 for Amazon Managed Prometheus.
+type RequestSigner func(req *http.Request) error
+
+// WithRequestSigner sets a hook that signs every request after its body and headers are set. The hook runs once per
+// attempt, so retried requests are signed again with a fresh timestamp. It should read POST bodies through
+// req.GetBody, which returns a new copy of the final body, so req.Body is left unread for sending.
+func WithRequestSigner(signer RequestSigner) Option {
+	return func(c *Client) {
+		c.signer = signer
+	}
+}
+
+type signingDoer struct {
+	next   doer
+	signer RequestSigner
+}
+
+func (d *signingDoer) Do(req *http.Request) (*http.Response, error) {
+	if err := d.signer(req); err != nil {
+		return nil, err
+	}
+	return d.next.Do(req)
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestRequestSigner(t *testing.T) {
+	query := &models.Query{
+		Expr:       "up",
+		Start:      time.Unix(0, 0),
+		End:        time.Unix(1234, 0),
+		RangeQuery: true,
+		Step:       1 * time.Second,
+	}
+
+	t.Run("signs final POST body and headers", func(t *testing.T) {
+		var signedBody, signedUserAgent string
+		signer := func(req *http.Request) error {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				return err
+			}
+			signedBody = string(b)
+			signedUserAgent = req.Header.Get("User-Agent")
+			req.Header.Set("Authorization", "signed")
+			return nil
+		}
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodPost, "http://localhost:9090", WithRequestSigner(signer))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "end=1234&query=up&start=0&step=1", signedBody)
+		require.Equal(t, defaultUserAgent, signedUserAgent)
+		require.Equal(t, "signed", doer.LastRequest().Header.Get("Authorization"))
+		body, err := io.ReadAll(doer.LastRequest().Body)
+		require.NoError(t, err)
+		require.Equal(t, signedBody, string(body))
+	})
+
+	t.Run("signs every retry attempt", func(t *testing.T) {
+		signed := 0
+		signer := func(req *http.Request) error {
+			signed++
+			return nil
+		}
+		doer := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable),
+			clienttest.Status(http.StatusOK),
+		))
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithRequestSigner(signer), WithRetryPolicy(testPolicy))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, 2, signed)
+	})
+
+	t.Run("returns signing error without sending request", func(t *testing.T) {
+		signErr := errors.New("no credentials")
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithRequestSigner(func(req *http.Request) error {
+			return signErr
+		}))
+
+		_, err := client.QueryRange(context.Background(), query)
+		require.ErrorIs(t, err, signErr)
+		require.Nil(t, doer.LastRequest())
+	})
+}
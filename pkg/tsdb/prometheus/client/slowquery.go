@@ -0,0 +1,68 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// WithSlowQueryThreshold logs a warning for every request that takes longer than the threshold, including reading
+// the response body. Zero disables the warning.
+func WithSlowQueryThreshold(threshold time.Duration) Option {
+	return func(c *Client) {
+		c.slowQueryThreshold = threshold
+	}
+}
+
+// WithLargeResponseThreshold logs a warning for every response whose decompressed body is larger than the threshold
+// in bytes. Zero disables the warning.
+func WithLargeResponseThreshold(threshold int64) Option {
+	return func(c *Client) {
+		c.largeResponseThreshold = threshold
+	}
+}
+
+// observeResponse wraps the decompressed body of a response to record its size and duration once it is closed.
+func (c *Client) observeResponse(req *http.Request, res *http.Response, start time.Time) {
+	if c.metrics == nil && c.slowQueryThreshold <= 0 && c.largeResponseThreshold <= 0 {
+		return
+	}
+
+	res.Body = &countingBody{ReadCloser: res.Body, onClose: func(size int64) {
+		endpoint := metricsEndpoint(req.URL.Path)
+		if c.metrics != nil {
+			c.metrics.size.WithLabelValues(endpoint).Observe(float64(size))
+		}
+
+		duration := c.since(start)
+		logger := c.logger.FromContext(req.Context())
+		if c.slowQueryThreshold > 0 && duration > c.slowQueryThreshold {
+			logger.Warn("Slow Prometheus request", "endpoint", endpoint, "duration", duration, "threshold", c.slowQueryThreshold)
+		}
+		if c.largeResponseThreshold > 0 && size > c.largeResponseThreshold {
+			logger.Warn("Large Prometheus response", "endpoint", endpoint, "size", size, "threshold", c.largeResponseThreshold)
+		}
+	}}
+}
+
+// countingBody counts the bytes read from a body and reports them when it is closed.
+type countingBody struct {
+	io.ReadCloser
+	n       int64
+	closed  bool
+	onClose func(n int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		b.onClose(b.n)
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+// recordingLogger records the messages of warnings.
+type recordingLogger struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {}
+func (l *recordingLogger) Info(msg string, args ...interface{})  {}
+func (l *recordingLogger) Error(msg string, args ...interface{}) {}
+func (l *recordingLogger) Warn(msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, msg)
+}
+func (l *recordingLogger) With(args ...interface{}) log.Logger        { return l }
+func (l *recordingLogger) Level() log.Level                           { return log.Debug }
+func (l *recordingLogger) FromContext(ctx context.Context) log.Logger { return l }
+
+func TestResponseObservation(t *testing.T) {
+	t.Run("records decompressed response size", func(t *testing.T) {
+		metrics := NewMetrics()
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			return responseWithBody("gzip", compress(t, "gzip", rawBody)), nil
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithMetrics(metrics))
+
+		res, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		readBody(t, res)
+
+		m := &dto.Metric{}
+		require.NoError(t, metrics.size.WithLabelValues("/api/v1/query_range").(prometheus.Histogram).Write(m))
+		require.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+		require.Equal(t, float64(len(rawBody)), m.GetHistogram().GetSampleSum())
+	})
+
+	t.Run("warns about large responses", func(t *testing.T) {
+		logger := &recordingLogger{}
+		body := strings.Repeat("a", 2048)
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090",
+			WithLogger(logger), WithLargeResponseThreshold(1024))
+
+		res, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		_, err = io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, []string{"Large Prometheus response"}, logger.warnings)
+	})
+
+	t.Run("warns about slow requests", func(t *testing.T) {
+		logger := &recordingLogger{}
+		doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+			time.Sleep(20 * time.Millisecond)
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+		})
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithLogger(logger), WithSlowQueryThreshold(10*time.Millisecond))
+
+		res, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, []string{"Slow Prometheus request"}, logger.warnings)
+	})
+
+	t.Run("does not warn below thresholds", func(t *testing.T) {
+		logger := &recordingLogger{}
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090",
+			WithLogger(logger), WithLargeResponseThreshold(1<<20), WithSlowQueryThreshold(time.Minute))
+
+		res, err := client.QueryRange(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		readBody(t, res)
+		require.Empty(t, logger.warnings)
+	})
+}
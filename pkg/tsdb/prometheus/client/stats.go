@@ -0,0 +1,83 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/converter"
+)
+
+// QueryStats are the statistics Prometheus returns for queries with models.Query.Stats set.
+type QueryStats struct {
+	Timings QueryTimings `json:"timings"`
+	Samples QuerySamples `json:"samples"`
+}
+
+// QueryTimings are the times spent in the phases of a query, in seconds.
+type QueryTimings struct {
+	EvalTotalTime        float64 `json:"evalTotalTime"`
+	ResultSortTime       float64 `json:"resultSortTime"`
+	QueryPreparationTime float64 `json:"queryPreparationTime"`
+	InnerEvalTime        float64 `json:"innerEvalTime"`
+	ExecQueueTime        float64 `json:"execQueueTime"`
+	ExecTotalTime        float64 `json:"execTotalTime"`
+}
+
+// QuerySamples are the numbers of samples a query loaded.
+type QuerySamples struct {
+	// TotalQueryableSamples is the number of samples read from storage over all steps.
+	TotalQueryableSamples int64 `json:"totalQueryableSamples"`
+	// PeakSamples is the maximum number of samples held in memory at once.
+	PeakSamples int64 `json:"peakSamples"`
+}
+
+// QueryExplanation is a node of the query plan that Thanos and other engines return for models.Query.Explain.
+type QueryExplanation struct {
+	Name     string              `json:"name"`
+	Children []*QueryExplanation `json:"children,omitempty"`
+}
+
+// addStats requests the statistics of a query when they are enabled.
+func addStats(qv map[string]string, enabled bool) {
+	if enabled {
+		qv["stats"] = "all"
+	}
+}
+
+// addExplain requests the query plan when it is enabled.
+func addExplain(qv map[string]string, enabled bool) {
+	if enabled {
+		qv["explain"] = "true"
+	}
+}
+
+// envelopeStats returns the statistics of a response, nil if it has none.
+func envelopeStats(env converter.Envelope) (*QueryStats, error) {
+	var stats *QueryStats
+	if err := decodeEnvelopeValue(env.Stats, &stats); err != nil {
+		return nil, fmt.Errorf("failed to decode query stats: %w", err)
+	}
+	return stats, nil
+}
+
+// envelopeExplanation returns the query plan of a response, nil if it has none.
+func envelopeExplanation(env converter.Envelope) (*QueryExplanation, error) {
+	var explanation *QueryExplanation
+	if err := decodeEnvelopeValue(env.Explanation, &explanation); err != nil {
+		return nil, fmt.Errorf("failed to decode query explanation: %w", err)
+	}
+	return explanation, nil
+}
+
+// decodeEnvelopeValue decodes a JSON value the converter read from the response into v. v is left untouched if there
+// is no value.
+func decodeEnvelopeValue(value any, v any) error {
+	if value == nil {
+		return nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+// maxResolution is the maximum number of points per series Prometheus returns for a range query.
+const maxResolution = 11000
+
+// ErrTooManyPoints is returned for range queries that would return more points per series than the limit set with
+// WithMaxPoints.
+var ErrTooManyPoints = errors.New("range query exceeds maximum number of points per series")
+
+// WithMaxPoints limits the number of points per series of range queries, computed from their range and step. Queries
+// above the limit fail with ErrTooManyPoints before they are sent. The default of 11000 matches the limit of
+// Prometheus, a limit of zero or less disables the check, e.g. for servers without that limit.
+func WithMaxPoints(limit int) Option {
+	return func(c *Client) {
+		c.maxPoints = limit
+	}
+}
+
+// checkPoints returns ErrTooManyPoints if the range query exceeds the maximum number of points. Like Prometheus it
+// divides the range by the step, queries without a step are left to the server.
+func (c *Client) checkPoints(tr models.TimeRange) error {
+	if c.maxPoints <= 0 || tr.Step <= 0 {
+		return nil
+	}
+	if points := tr.End.Sub(tr.Start) / tr.Step; points > time.Duration(c.maxPoints) {
+		return fmt.Errorf("%w: %d points with step %s, maximum is %d, increase the step or decrease the range", ErrTooManyPoints, points, tr.Step, c.maxPoints)
+	}
+	return nil
+}
+
+// FillStep sets the step of a range query so it returns about targetPoints points per series, like Parse computes the
+// step for a panel with targetPoints max data points. The step is never smaller than minStep and within the resolution
+// limit of Prometheus.
+func FillStep(q *models.Query, minStep time.Duration, targetPoints int) {
+	q.Step = models.CalculateStep(q.Start, q.End, minStep, int64(targetPoints))
+}
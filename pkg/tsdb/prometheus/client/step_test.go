@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestFillStep(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	tests := []struct {
+		name         string
+		r            time.Duration
+		minStep      time.Duration
+		targetPoints int
+		expected     time.Duration
+	}{
+		{name: "divides range by target points", r: time.Hour, minStep: time.Second, targetPoints: 360, expected: 10 * time.Second},
+		{name: "uses min step for short ranges", r: time.Hour, minStep: 15 * time.Second, targetPoints: 1000, expected: 15 * time.Second},
+		{name: "uses min step without target points", r: time.Hour, minStep: 30 * time.Second, targetPoints: 0, expected: 30 * time.Second},
+		{name: "clamps to max resolution", r: 365 * 24 * time.Hour, minStep: time.Second, targetPoints: 1000000, expected: time.Hour},
+		{name: "rounds like Grafana intervals", r: time.Second, minStep: 0, targetPoints: 3, expected: 200 * time.Millisecond},
+		{name: "uses at least one millisecond", r: time.Millisecond, minStep: 0, targetPoints: 100, expected: time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &models.Query{Start: start, End: start.Add(tt.r), RangeQuery: true}
+			FillStep(q, tt.minStep, tt.targetPoints)
+			require.Equal(t, tt.expected, q.Step)
+			require.LessOrEqual(t, int64(tt.r/q.Step), int64(maxResolution))
+		})
+	}
+}
+
+func TestMaxPoints(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	t.Run("rejects queries above the default limit", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		q := &models.Query{Expr: "up", Start: start, End: start.Add(11001 * time.Second), Step: time.Second, RangeQuery: true}
+
+		_, err := client.QueryRange(context.Background(), q)
+		require.ErrorIs(t, err, ErrTooManyPoints)
+		require.ErrorContains(t, err, "11001 points")
+		require.Nil(t, doer.LastRequest())
+	})
+
+	t.Run("sends queries at the limit", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		q := &models.Query{Expr: "up", Start: start, End: start.Add(11000 * time.Second), Step: time.Second, RangeQuery: true}
+
+		res, err := client.QueryRange(context.Background(), q)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	})
+
+	t.Run("uses configured limit", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Echo), http.MethodGet, "http://localhost:9090", WithMaxPoints(100))
+		q := &models.Query{Expr: "up", Start: start, End: start.Add(101 * time.Second), Step: time.Second, RangeQuery: true}
+
+		_, err := client.QueryRange(context.Background(), q)
+		require.ErrorIs(t, err, ErrTooManyPoints)
+	})
+
+	t.Run("can be disabled", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Echo), http.MethodGet, "http://localhost:9090", WithMaxPoints(0))
+		q := &models.Query{Expr: "up", Start: start, End: start.Add(time.Hour * 24), Step: time.Second, RangeQuery: true}
+
+		res, err := client.QueryRange(context.Background(), q)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	})
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+// Series is a single series of a matrix or vector result.
+type Series struct {
+	Metric  map[string]string
+	Samples []Sample
+}
+
+// Sample is a single value of a series.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// ResultIterator decodes the series of a query result one at a time while reading the response body, so large results
+// do not have to be held in memory. It must be closed after use.
+//
+//	it, err := client.QueryRangeStream(ctx, q)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		series := it.At()
+//	}
+//	return it.Err()
+type ResultIterator struct {
+	body    io.ReadCloser
+	dec     *json.Decoder
+	current Series
+	err     error
+	done    bool
+}
+
+// QueryRangeStream runs a range query and returns an iterator over the series of the result.
+func (c *Client) QueryRangeStream(ctx context.Context, q *models.Query) (*ResultIterator, error) {
+	res, err := c.QueryRange(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkContentType(res); err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+
+	it := &ResultIterator{body: res.Body, dec: json.NewDecoder(res.Body)}
+	if err := it.seekResult(); err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+// Next decodes the next series. It returns false when there are no more series or decoding failed, see Err.
+func (it *ResultIterator) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	if !it.dec.More() {
+		it.done = true
+		// Consume the closing bracket of the result array.
+		if _, err := it.dec.Token(); err != nil {
+			it.err = err
+		}
+		return false
+	}
+
+	var s streamSeries
+	if err := it.dec.Decode(&s); err != nil {
+		it.err = fmt.Errorf("failed to decode series: %w", err)
+		return false
+	}
+
+	it.current = Series{Metric: s.Metric, Samples: s.Values}
+	if s.Value != nil {
+		it.current.Samples = []Sample{*s.Value}
+	}
+	return true
+}
+
+// At returns the series decoded by the last call to Next.
+func (it *ResultIterator) At() Series {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ResultIterator) Err() error {
+	return it.err
+}
+
+// Close closes the response body. It can be called at any time to stop the iteration early.
+func (it *ResultIterator) Close() error {
+	it.done = true
+	return it.body.Close()
+}
+
+// seekResult moves the decoder to the first element of data.result. Other fields are skipped.
+func (it *ResultIterator) seekResult() error {
+	if err := expectDelim(it.dec, '{'); err != nil {
+		return err
+	}
+
+	for it.dec.More() {
+		key, err := it.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "status":
+			var status string
+			if err := it.dec.Decode(&status); err != nil {
+				return err
+			}
+			if status != "success" {
+				return fmt.Errorf("unexpected response status %q", status)
+			}
+		case "data":
+			return it.seekDataResult()
+		default:
+			var skip json.RawMessage
+			if err := it.dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+
+	return errors.New("response does not contain data")
+}
+
+func (it *ResultIterator) seekDataResult() error {
+	if err := expectDelim(it.dec, '{'); err != nil {
+		return err
+	}
+
+	for it.dec.More() {
+		key, err := it.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "resultType":
+			var resultType string
+			if err := it.dec.Decode(&resultType); err != nil {
+				return err
+			}
+			if resultType != string(models.ResultTypeMatrix) && resultType != string(models.ResultTypeVector) {
+				return fmt.Errorf("unsupported result type %q", resultType)
+			}
+		case "result":
+			t, err := it.dec.Token()
+			if err != nil {
+				return err
+			}
+			switch t {
+			case json.Delim('['):
+				return nil
+			case nil:
+				// Some Prometheus compatible stores send null instead of an empty result.
+				it.done = true
+				return nil
+			}
+			return fmt.Errorf("unexpected token %v, expected %v", t, json.Delim('['))
+		default:
+			var skip json.RawMessage
+			if err := it.dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+
+	return errors.New("response does not contain a result")
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("unexpected token %v, expected %v", t, delim)
+	}
+	return nil
+}
+
+type streamSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values []Sample          `json:"values"`
+	Value  *Sample           `json:"value"`
+}
+
+// UnmarshalJSON decodes a sample in the [<unix seconds>, "<value>"] format of the Prometheus API.
+func (s *Sample) UnmarshalJSON(b []byte) error {
+	var raw [2]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	var ts float64
+	if err := json.Unmarshal(raw[0], &ts); err != nil {
+		return fmt.Errorf("invalid sample timestamp: %w", err)
+	}
+
+	var value string
+	if err := json.Unmarshal(raw[1], &value); err != nil {
+		return fmt.Errorf("invalid sample value: %w", err)
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid sample value: %w", err)
+	}
+
+	s.Time = time.UnixMilli(int64(ts * 1000.0)).UTC()
+	s.Value = v
+	return nil
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func streamClient(t *testing.T, status int, body *closeRecorder) *Client {
+	doer := clienttest.NewFakeDoer(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       body,
+		}, nil
+	})
+	return newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+}
+
+func TestQueryRangeStream(t *testing.T) {
+	t.Run("iterates over series", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(matrixResponse)}
+		it, err := streamClient(t, http.StatusOK, body).QueryRangeStream(context.Background(), rangeQuery)
+		require.NoError(t, err)
+
+		var series []Series
+		for it.Next() {
+			series = append(series, it.At())
+		}
+		require.NoError(t, it.Err())
+		require.NoError(t, it.Close())
+		require.True(t, body.closed)
+
+		require.Len(t, series, 2)
+		require.Equal(t, map[string]string{"__name__": "up", "job": "prometheus"}, series[0].Metric)
+		require.Equal(t, []Sample{
+			{Time: time.Unix(1641889530, 0).UTC(), Value: 1},
+			{Time: time.Unix(1641889531, 0).UTC(), Value: 0},
+		}, series[0].Samples)
+		require.Equal(t, "node", series[1].Metric["job"])
+	})
+
+	t.Run("iterates over vector", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[1641889530.5,"NaN"]}]}}`)}
+		it, err := streamClient(t, http.StatusOK, body).QueryRangeStream(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, it.Close())
+		}()
+
+		require.True(t, it.Next())
+		require.Len(t, it.At().Samples, 1)
+		require.Equal(t, time.UnixMilli(1641889530500).UTC(), it.At().Samples[0].Time)
+		require.True(t, math.IsNaN(it.At().Samples[0].Value))
+		require.False(t, it.Next())
+		require.NoError(t, it.Err())
+	})
+
+	t.Run("returns no series for null result", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(`{"status":"success","data":{"resultType":"matrix","result":null}}`)}
+		it, err := streamClient(t, http.StatusOK, body).QueryRangeStream(context.Background(), rangeQuery)
+		require.NoError(t, err)
+
+		require.False(t, it.Next())
+		require.NoError(t, it.Err())
+		require.NoError(t, it.Close())
+		require.True(t, body.closed)
+	})
+
+	t.Run("propagates decode errors", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[1,"x"]]}]}}`)}
+		it, err := streamClient(t, http.StatusOK, body).QueryRangeStream(context.Background(), rangeQuery)
+		require.NoError(t, err)
+
+		require.False(t, it.Next())
+		require.ErrorContains(t, it.Err(), "invalid sample value")
+		require.NoError(t, it.Close())
+	})
+
+	t.Run("stops early on close", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(matrixResponse)}
+		it, err := streamClient(t, http.StatusOK, body).QueryRangeStream(context.Background(), rangeQuery)
+		require.NoError(t, err)
+
+		require.True(t, it.Next())
+		require.NoError(t, it.Close())
+		require.True(t, body.closed)
+		require.False(t, it.Next())
+	})
+
+	t.Run("returns error for unsupported result type", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(`{"status":"success","data":{"resultType":"scalar","result":[1,"1"]}}`)}
+		_, err := streamClient(t, http.StatusOK, body).QueryRangeStream(context.Background(), rangeQuery)
+		require.EqualError(t, err, `unsupported result type "scalar"`)
+		require.True(t, body.closed)
+	})
+
+	t.Run("returns PrometheusError for error response", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader(`{"status":"error","errorType":"execution","error":"query processing would load too many samples into memory"}`)}
+		_, err := streamClient(t, http.StatusUnprocessableEntity, body).QueryRangeStream(context.Background(), rangeQuery)
+		var promErr *PrometheusError
+		require.ErrorAs(t, err, &promErr)
+		require.Equal(t, ErrorTypeExecution, promErr.Type)
+	})
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// WithTableFrames returns the series of parsed results as a single table frame instead of a frame per series, for
+// table panels. The table has a row per sample with a Time column, a string column per label and a column per value
+// field of the series, e.g. Value and the Raw column of WithRawValues. Frames that are not series, like native
+// histograms, follow the table as they are.
+func WithTableFrames(enabled bool) Option {
+	return func(c *Client) {
+		c.tableFrames = enabled
+	}
+}
+
+// tableFrames merges the series frames into one table frame. Series without a label get an empty string in its
+// column, series without a value field zero values. The metadata of the first series is kept on the table.
+func tableFrames(frames data.Frames) data.Frames {
+	var series, rest data.Frames
+	for _, frame := range frames {
+		if isSeriesFrame(frame) {
+			series = append(series, frame)
+		} else {
+			rest = append(rest, frame)
+		}
+	}
+	if len(series) == 0 {
+		return frames
+	}
+
+	names := map[string]bool{}
+	for _, frame := range series {
+		for name := range frameLabels(frame) {
+			names[name] = true
+		}
+	}
+	labelNames := make([]string, 0, len(names))
+	for name := range names {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+
+	timeField := data.NewFieldFromFieldType(data.FieldTypeTime, 0)
+	timeField.Name = data.TimeSeriesTimeFieldName
+	labelFields := make([]*data.Field, len(labelNames))
+	for i, name := range labelNames {
+		labelFields[i] = data.NewFieldFromFieldType(data.FieldTypeString, 0)
+		labelFields[i].Name = name
+	}
+	var valueFields []*data.Field
+	valueIndex := map[string]int{}
+	for _, frame := range series {
+		for _, field := range frame.Fields[1:] {
+			if _, ok := valueIndex[field.Name]; !ok {
+				valueIndex[field.Name] = len(valueFields)
+				valueField := data.NewFieldFromFieldType(field.Type(), 0)
+				valueField.Name = field.Name
+				valueFields = append(valueFields, valueField)
+			}
+		}
+	}
+
+	for _, frame := range series {
+		lbls := frameLabels(frame)
+		times := frame.Fields[0]
+		n := times.Len()
+		for i := 0; i < n; i++ {
+			timeField.Append(times.At(i).(time.Time))
+			for j, name := range labelNames {
+				labelFields[j].Append(lbls[name])
+			}
+		}
+
+		filled := make([]bool, len(valueFields))
+		for _, field := range frame.Fields[1:] {
+			j := valueIndex[field.Name]
+			filled[j] = true
+			for i := 0; i < n; i++ {
+				valueFields[j].Append(field.At(i))
+			}
+		}
+		for j, ok := range filled {
+			if !ok {
+				valueFields[j].Extend(n)
+			}
+		}
+	}
+
+	fields := append([]*data.Field{timeField}, labelFields...)
+	table := data.NewFrame("", append(fields, valueFields...)...)
+	table.Meta = &data.FrameMeta{
+		Type:    data.FrameTypeTable,
+		Custom:  series[0].Meta.Custom,
+		Notices: series[0].Meta.Notices,
+	}
+	return append(data.Frames{table}, rest...)
+}
+
+// isSeriesFrame returns whether the frame holds the samples of a single matrix or vector series.
+func isSeriesFrame(frame *data.Frame) bool {
+	if frame.Meta == nil || (frame.Meta.Type != data.FrameTypeTimeSeriesMulti && frame.Meta.Type != data.FrameTypeNumericMulti) {
+		return false
+	}
+	return len(frame.Fields) >= 2 &&
+		frame.Fields[0].Type() == data.FieldTypeTime &&
+		frame.Fields[1].Type() == data.FieldTypeFloat64 &&
+		frame.Fields[1].Name == data.TimeSeriesValueFieldName
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+)
+
+func TestTableFrames(t *testing.T) {
+	t.Run("returns a row per sample with label columns", func(t *testing.T) {
+		body := strings.Replace(matrixResponse, `{"__name__": "up", "job": "node"}`, `{"__name__": "up", "job": "node", "instance": "a:9100"}`, 1)
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090", WithTableFrames(true))
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+
+		table := frames[0]
+		require.Equal(t, data.FrameTypeTable, table.Meta.Type)
+		names := make([]string, 0, len(table.Fields))
+		for _, field := range table.Fields {
+			names = append(names, field.Name)
+			require.Nil(t, field.Labels)
+		}
+		require.Equal(t, []string{"Time", "__name__", "instance", "job", "Value"}, names)
+		require.Equal(t, data.FieldTypeString, table.Fields[2].Type())
+		require.Equal(t, 3, table.Rows())
+
+		require.Equal(t, []any{time.Unix(1641889530, 0).UTC(), "up", "", "prometheus", 1.0}, table.RowCopy(0))
+		require.Equal(t, []any{time.Unix(1641889531, 0).UTC(), "up", "", "prometheus", 0.0}, table.RowCopy(1))
+		require.Equal(t, []any{time.Unix(1641889530, 0).UTC(), "up", "a:9100", "node", 1.0}, table.RowCopy(2))
+	})
+
+	t.Run("keeps raw values", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090", WithTableFrames(true), WithRawValues(true))
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+
+		table := frames[0]
+		names := make([]string, 0, len(table.Fields))
+		for _, field := range table.Fields {
+			names = append(names, field.Name)
+		}
+		require.Equal(t, []string{"Time", "__name__", "job", "Value", "Raw"}, names)
+		require.Equal(t, data.FieldTypeString, table.Fields[4].Type())
+		require.Equal(t, []any{time.Unix(1641889530, 0).UTC(), "up", "prometheus", 1.0, "1"}, table.RowCopy(0))
+		require.Equal(t, []any{time.Unix(1641889531, 0).UTC(), "up", "prometheus", 0.0, "0"}, table.RowCopy(1))
+		require.Equal(t, []any{time.Unix(1641889530, 0).UTC(), "up", "node", 1.0, "1"}, table.RowCopy(2))
+	})
+
+	t.Run("orders rows by series when sorted", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090", WithTableFrames(true), WithSortedSeries(true))
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Equal(t, "node", frames[0].Fields[2].At(0))
+		require.Equal(t, "prometheus", frames[0].Fields[2].At(1))
+	})
+
+	t.Run("keeps warnings", func(t *testing.T) {
+		body := strings.Replace(matrixResponse, `"status": "success",`, `"status": "success", "warnings": ["results may be incomplete"],`, 1)
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, body, nil)), http.MethodGet, "http://localhost:9090", WithTableFrames(true))
+
+		result, err := client.QueryRangeResult(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Equal(t, []string{"results may be incomplete"}, result.Warnings)
+		require.Len(t, result.Frames[0].Meta.Notices, 1)
+	})
+
+	t.Run("keeps frames that are not series", func(t *testing.T) {
+		body, err := os.ReadFile("../converter/testdata/prom-matrix-histogram-no-labels.json")
+		require.NoError(t, err)
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, string(body), nil)), http.MethodGet, "http://localhost:9090", WithTableFrames(true))
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+		require.Equal(t, data.FrameType("heatmap-cells"), frames[0].Meta.Type)
+	})
+
+	t.Run("returns frame per series by default", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Respond(http.StatusOK, matrixResponse, nil)), http.MethodGet, "http://localhost:9090")
+
+		frames, err := client.QueryRangeFrames(context.Background(), rangeQuery)
+		require.NoError(t, err)
+		require.Len(t, frames, 2)
+		require.Equal(t, data.FrameTypeTimeSeriesMulti, frames[0].Meta.Type)
+	})
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxExprAttributeLength limits the size of the expression recorded on spans, as expressions can be huge.
+const maxExprAttributeLength = 1024
+
+// WithTracer creates a span for each request to Prometheus. No spans are created without a tracer.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(c *Client) {
+		c.tracer = tracer
+	}
+}
+
+// startSpan starts the span of a single client call. The returned span is a no-op span if no tracer is configured.
+func (c *Client) startSpan(ctx context.Context, expr string) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+
+	var attrs []attribute.KeyValue
+	if expr != "" {
+		attrs = append(attrs, attribute.String("expr", truncate(expr, maxExprAttributeLength)))
+	}
+
+	return c.tracer.Start(ctx, "datasource.prometheus.client", trace.WithAttributes(attrs...))
+}
+
+// recordSpan adds the request details and the outcome to the span of the request.
+func (c *Client) recordSpan(req *http.Request, res *http.Response, err error) {
+	if c.tracer == nil {
+		return
+	}
+
+	span := trace.SpanFromContext(req.Context())
+	span.SetAttributes(
+		attribute.String("endpoint", req.URL.Path),
+		attribute.String("http.method", req.Method),
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", res.StatusCode))
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+}
+
+// truncate shortens s to at most n bytes without splitting a multi byte character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
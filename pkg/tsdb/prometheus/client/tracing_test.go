@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracing(t *testing.T) {
+	query := &models.Query{
+		Expr:       "up",
+		Start:      time.Unix(0, 0),
+		End:        time.Unix(1234, 0),
+		RangeQuery: true,
+		Step:       1 * time.Second,
+	}
+
+	t.Run("creates span with request details", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Status(http.StatusOK)), http.MethodGet, "http://localhost:9090", WithTracer(tracer))
+
+		_, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		attrs := spanAttributes(spans[0])
+		require.Equal(t, "up", attrs["expr"].AsString())
+		require.Equal(t, "/api/v1/query_range", attrs["endpoint"].AsString())
+		require.Equal(t, http.MethodGet, attrs["http.method"].AsString())
+		require.Equal(t, int64(http.StatusOK), attrs["http.status_code"].AsInt64())
+		require.Equal(t, codes.Unset, spans[0].Status().Code)
+	})
+
+	t.Run("sets error status from HTTP status", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Status(http.StatusBadGateway)), http.MethodGet, "http://localhost:9090", WithTracer(tracer))
+
+		_, err := client.QueryInstant(context.Background(), query)
+		require.ErrorContains(t, err, "502 Bad Gateway")
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		require.Equal(t, codes.Error, spans[0].Status().Code)
+	})
+
+	t.Run("records request errors", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Fail(errors.New("connection refused"))), http.MethodGet, "http://localhost:9090", WithTracer(tracer))
+
+		_, err := client.QueryRange(context.Background(), query)
+		require.Error(t, err)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		require.Equal(t, codes.Error, spans[0].Status().Code)
+		require.Equal(t, "connection refused", spans[0].Status().Description)
+		require.Len(t, spans[0].Events(), 1)
+	})
+
+	t.Run("truncates long expressions", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Status(http.StatusOK)), http.MethodPost, "http://localhost:9090", WithTracer(tracer))
+
+		long := *query
+		long.Expr = strings.Repeat("ü", maxExprAttributeLength)
+		_, err := client.QueryRange(context.Background(), &long)
+		require.NoError(t, err)
+
+		expr := spanAttributes(recorder.Ended()[0])["expr"].AsString()
+		require.Len(t, expr, maxExprAttributeLength)
+		require.True(t, strings.HasPrefix(long.Expr, expr))
+	})
+
+	t.Run("records retry attempts", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		next := clienttest.NewFakeDoer(clienttest.Sequence(clienttest.Status(http.StatusServiceUnavailable),
+			clienttest.Status(http.StatusServiceUnavailable),
+			clienttest.Status(http.StatusOK),
+		))
+		client := newTestClient(t, next, http.MethodGet, "http://localhost:9090", WithTracer(tracer), WithRetryPolicy(testPolicy))
+
+		res, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "3", res.Header.Get(AttemptsHeader))
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		require.Equal(t, int64(3), spanAttributes(spans[0])["http.attempts"].AsInt64())
+	})
+
+	t.Run("works without tracer", func(t *testing.T) {
+		client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Status(http.StatusOK)), http.MethodGet, "http://localhost:9090")
+		_, err := client.QueryRange(context.Background(), query)
+		require.NoError(t, err)
+	})
+}
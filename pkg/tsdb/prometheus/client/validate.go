@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+// ValidationError is returned for expressions that are not valid PromQL.
+type ValidationError struct {
+	Expr string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid PromQL expression %q: %v", e.Expr, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// WithValidation makes QueryRange and QueryInstant check the syntax of expressions with ValidateExpr before sending
+// them, so malformed expressions fail without a request. It should only be enabled for interpolated expressions, as
+// template variables like $__rate_interval are not valid PromQL.
+func WithValidation(enabled bool) Option {
+	return func(c *Client) {
+		c.validate = enabled
+	}
+}
+
+// ValidateExpr checks the syntax of a PromQL expression. It returns a ValidationError for invalid expressions.
+func (c *Client) ValidateExpr(expr string) error {
+	if _, err := parser.ParseExpr(expr); err != nil {
+		return &ValidationError{Expr: expr, Err: err}
+	}
+	return nil
+}
+
+func (c *Client) validateQuery(q *models.Query) error {
+	if !c.validate {
+		return nil
+	}
+	return c.ValidateExpr(q.Expr)
+}
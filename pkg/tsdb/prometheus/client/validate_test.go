@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/client/clienttest"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/models"
+)
+
+func TestValidateExpr(t *testing.T) {
+	client := newTestClient(t, clienttest.NewFakeDoer(clienttest.Echo), http.MethodGet, "http://localhost:9090")
+
+	t.Run("accepts valid expressions", func(t *testing.T) {
+		require.NoError(t, client.ValidateExpr(`sum by (job) (rate(http_requests_total{code=~"5.."}[5m]))`))
+		require.NoError(t, client.ValidateExpr(`up @ 1609746000`))
+	})
+
+	t.Run("rejects invalid expressions", func(t *testing.T) {
+		err := client.ValidateExpr(`sum(rate(up[5m])`)
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, `sum(rate(up[5m])`, validationErr.Expr)
+	})
+
+	t.Run("gates queries when enabled", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090", WithValidation(true))
+		q := &models.Query{Expr: "rate(up[$__rate_interval])", Start: time.Unix(0, 0), End: time.Unix(100, 0), Step: time.Second}
+
+		_, err := client.QueryRange(context.Background(), q)
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		_, err = client.QueryInstant(context.Background(), q)
+		require.ErrorAs(t, err, &validationErr)
+		require.Nil(t, doer.LastRequest())
+
+		q.Expr = "rate(up[5m])"
+		res, err := client.QueryRange(context.Background(), q)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.NotNil(t, doer.LastRequest())
+	})
+
+	t.Run("does not validate by default", func(t *testing.T) {
+		doer := clienttest.NewFakeDoer(clienttest.Echo)
+		client := newTestClient(t, doer, http.MethodGet, "http://localhost:9090")
+		q := &models.Query{Expr: "rate(up[$__rate_interval])", Start: time.Unix(0, 0), End: time.Unix(100, 0), Step: time.Second}
+
+		res, err := client.QueryRange(context.Background(), q)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	})
+}
@@ -21,16 +21,49 @@ func logf(format string, a ...any) {
 
 type Options struct {
 	Dataplane bool
+	// RawValues adds a string field with the sample values as sent by the server next to the value field of matrix
+	// and vector results, for values that lose precision as float64, e.g. very large counters.
+	RawValues bool
+	// HistogramTotals adds a time series frame with the count and sum of every native histogram sample next to the
+	// heatmap-cells frame of that series.
+	HistogramTotals bool
 }
 
+// RawValueFieldName is the name of the field holding the raw sample values, see Options.RawValues.
+const RawValueFieldName = "Raw"
+
+// Names of the fields in the histogram totals frame, see Options.HistogramTotals.
+const (
+	HistogramCountFieldName = "Count"
+	HistogramSumFieldName   = "Sum"
+)
+
 func rspErr(e error) backend.DataResponse {
 	return backend.DataResponse{Error: e}
 }
 
+// Envelope holds the parts of a response besides the result. They are also set on the frames, but responses without
+// series have no frames to carry them.
+type Envelope struct {
+	Warnings []string
+	Infos    []string
+	// Stats and Explanation are the decoded JSON values of data.stats and data.explanation, nil if they are missing.
+	Stats       any
+	Explanation any
+}
+
 // ReadPrometheusStyleResult will read results from a prometheus or loki server and return data frames
 func ReadPrometheusStyleResult(jIter *jsoniter.Iterator, opt Options) backend.DataResponse {
+	rsp, _ := ReadPrometheusStyleResultEnvelope(jIter, opt)
+	return rsp
+}
+
+// ReadPrometheusStyleResultEnvelope reads results like ReadPrometheusStyleResult and also returns the envelope of the
+// response.
+func ReadPrometheusStyleResultEnvelope(jIter *jsoniter.Iterator, opt Options) (backend.DataResponse, Envelope) {
 	iter := sdkjsoniter.NewIterator(jIter)
 	var rsp backend.DataResponse
+	var env Envelope
 	status := "unknown"
 	errorType := ""
 	promErrString := ""
@@ -39,38 +72,46 @@ func ReadPrometheusStyleResult(jIter *jsoniter.Iterator, opt Options) backend.Da
 l1Fields:
 	for l1Field, err := iter.ReadObject(); ; l1Field, err = iter.ReadObject() {
 		if err != nil {
-			return rspErr(err)
+			return rspErr(err), env
 		}
 		switch l1Field {
 		case "status":
 			if status, err = iter.ReadString(); err != nil {
-				return rspErr(err)
+				return rspErr(err), env
 			}
 
 		case "data":
-			rsp = readPrometheusData(iter, opt)
+			rsp = readPrometheusData(iter, opt, &env)
 			if rsp.Error != nil {
-				return rsp
+				return rsp, env
 			}
 
 		case "error":
 			if promErrString, err = iter.ReadString(); err != nil {
-				return rspErr(err)
+				return rspErr(err), env
 			}
 
 		case "errorType":
 			if errorType, err = iter.ReadString(); err != nil {
-				return rspErr(err)
+				return rspErr(err), env
 			}
 
 		case "warnings":
-			if warnings, err = readWarnings(iter); err != nil {
-				return rspErr(err)
+			if warnings, err = readNotices(iter, data.NoticeSeverityWarning); err != nil {
+				return rspErr(err), env
+			}
+			env.Warnings = noticeTexts(warnings)
+
+		case "infos":
+			infos, err := readNotices(iter, data.NoticeSeverityInfo)
+			if err != nil {
+				return rspErr(err), env
 			}
+			env.Infos = noticeTexts(infos)
 
 		case "":
 			if err != nil {
-				return rspErr(err)
+				return rspErr(err), env
 			}
 			break l1Fields
 
@@ -78,7 +119,7 @@ l1Fields:
 			v, err := iter.Read()
 			if err != nil {
 				rsp.Error = err
-				return rsp
+				return rsp, env
 			}
 			logf("[ROOT] TODO, support key: %s / %v\n", l1Field, v)
 		}
@@ -87,7 +128,7 @@ l1Fields:
 	if status == "error" {
 		return backend.DataResponse{
 			Error: fmt.Errorf("%s: %s", errorType, promErrString),
-		}
+		}, env
 	}
 
 	if len(warnings) > 0 {
@@ -99,10 +140,11 @@ l1Fields:
 		}
 	}
 
-	return rsp
+	return rsp, env
 }
 
-func readWarnings(iter *sdkjsoniter.Iterator) ([]data.Notice, error) {
+// readNotices reads an array of strings, like the warnings of a response, as notices of the given severity.
+func readNotices(iter *sdkjsoniter.Iterator, severity data.NoticeSeverity) ([]data.Notice, error) {
 	warnings := []data.Notice{}
 	next, err := iter.WhatIsNext()
 	if err != nil {
@@ -127,7 +169,7 @@ func readWarnings(iter *sdkjsoniter.Iterator) ([]data.Notice, error) {
 				return nil, err
 			}
 			notice := data.Notice{
-				Severity: data.NoticeSeverityWarning,
+				Severity: severity,
 				Text:     s,
 			}
 			warnings = append(warnings, notice)
@@ -137,7 +179,20 @@ func readWarnings(iter *sdkjsoniter.Iterator) ([]data.Notice, error) {
 	return warnings, nil
 }
 
-func readPrometheusData(iter *sdkjsoniter.Iterator, opt Options) backend.DataResponse {
+func noticeTexts(notices []data.Notice) []string {
+	if len(notices) == 0 {
+		return nil
+	}
+	texts := make([]string, 0, len(notices))
+	for _, notice := range notices {
+		texts = append(texts, notice.Text)
+	}
+	return texts
+}
+
+// readPrometheusData reads the data of a response. The statistics and the query plan are stored in env as well as on
+// the first frame.
+func readPrometheusData(iter *sdkjsoniter.Iterator, opt Options, env *Envelope) backend.DataResponse {
 	var rsp backend.DataResponse
 	t, err := iter.WhatIsNext()
 	if err != nil {
@@ -206,17 +261,21 @@ l1Fields:
 		case "stats":
 			v, err := iter.Read()
 			if err != nil {
-				rspErr(err)
+				return rspErr(err)
 			}
+			env.Stats = v
 			if len(rsp.Frames) > 0 {
-				meta := rsp.Frames[0].Meta
-				if meta == nil {
-					meta = &data.FrameMeta{}
-					rsp.Frames[0].Meta = meta
-				}
-				meta.Custom = map[string]any{
-					"stats": v,
-				}
+				setCustomMeta(rsp.Frames[0], "stats", v)
+			}
+
+		case "explanation":
+			v, err := iter.Read()
+			if err != nil {
+				return rspErr(err)
+			}
+			env.Explanation = v
+			if len(rsp.Frames) > 0 {
+				setCustomMeta(rsp.Frames[0], "explanation", v)
 			}
 
 		case "":
@@ -242,6 +301,16 @@ l1Fields:
 
 // will read the result object based on the resultType and return a DataResponse
 func readResult(resultType string, rsp backend.DataResponse, iter *sdkjsoniter.Iterator, opt Options, encodingFlags []string) backend.DataResponse {
+	// Some backends send a null result instead of an empty one when nothing matched.
+	if next, err := iter.WhatIsNext(); err != nil {
+		return rspErr(err)
+	} else if next == jsoniter.NilValue {
+		if _, err := iter.ReadNil(); err != nil {
+			return rspErr(err)
+		}
+		return rsp
+	}
+
 	switch resultType {
 	case "matrix", "vector":
 		rsp = readMatrixOrVectorMulti(iter, resultType, opt)
@@ -550,7 +619,7 @@ func readScalar(iter *sdkjsoniter.Iterator, dataPlane bool) backend.DataResponse
 	valueField.Name = data.TimeSeriesValueFieldName
 	valueField.Labels = data.Labels{}
 
-	t, v, err := readTimeValuePair(iter)
+	t, v, _, err := readTimeValuePair(iter)
 	if err != nil {
 		rsp.Error = err
 		return rsp
@@ -585,6 +654,18 @@ func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt
 		valueField := data.NewFieldFromFieldType(data.FieldTypeFloat64, 0)
 		valueField.Name = data.TimeSeriesValueFieldName
 		valueField.Labels = data.Labels{}
+		var rawField *data.Field
+		if opt.RawValues {
+			rawField = data.NewFieldFromFieldType(data.FieldTypeString, 0)
+			rawField.Name = RawValueFieldName
+		}
+		appendSample := func(t time.Time, v float64, raw string) {
+			timeField.Append(t)
+			valueField.Append(v)
+			if rawField != nil {
+				rawField.Append(raw)
+			}
+		}
 
 		var histogram *histogramInfo
 
@@ -599,12 +680,11 @@ func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt
 				}
 
 			case "value":
-				t, v, err := readTimeValuePair(iter)
+				t, v, raw, err := readTimeValuePair(iter)
 				if err != nil {
 					return rspErr(err)
 				}
-				timeField.Append(t)
-				valueField.Append(v)
+				appendSample(t, v, raw)
 
 			// nolint:goconst
 			case "values":
@@ -612,12 +692,11 @@ func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt
 					if err != nil {
 						return rspErr(err)
 					}
-					t, v, err := readTimeValuePair(iter)
+					t, v, raw, err := readTimeValuePair(iter)
 					if err != nil {
 						return rspErr(err)
 					}
-					timeField.Append(t)
-					valueField.Append(v)
+					appendSample(t, v, raw)
 				}
 
 			case "histogram":
@@ -660,8 +739,22 @@ func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt
 				frame.Name = "" // only set the name if useful
 			}
 			rsp.Frames = append(rsp.Frames, frame)
+			if opt.HistogramTotals {
+				histogram.totalCount.Labels = valueField.Labels
+				histogram.sum.Labels = valueField.Labels
+				totals := data.NewFrame("", histogram.totalTime, histogram.totalCount, histogram.sum)
+				totals.Meta = &data.FrameMeta{
+					Type:   data.FrameTypeTimeSeriesMulti,
+					Custom: resultTypeToCustomMeta(resultType),
+				}
+				rsp.Frames = append(rsp.Frames, totals)
+			}
 		} else {
 			frame := data.NewFrame("", timeField, valueField)
+			if rawField != nil {
+				rawField.Labels = valueField.Labels
+				frame.Fields = append(frame.Fields, rawField)
+			}
 			frame.Meta = &data.FrameMeta{
 				Type:   data.FrameTypeTimeSeriesMulti,
 				Custom: resultTypeToCustomMeta(resultType),
@@ -679,32 +772,34 @@ func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt
 	return rsp
 }
 
-func readTimeValuePair(iter *sdkjsoniter.Iterator) (time.Time, float64, error) {
+// readTimeValuePair reads a sample. Besides the parsed value it returns the value as sent by the server. NaN, +Inf
+// and -Inf are parsed to their float64 counterparts.
+func readTimeValuePair(iter *sdkjsoniter.Iterator) (time.Time, float64, string, error) {
 	if _, err := iter.ReadArray(); err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	t, err := iter.ReadFloat64()
 	if err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	if _, err = iter.ReadArray(); err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	var v string
 	if v, err = iter.ReadString(); err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	if _, err = iter.ReadArray(); err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	tt := timeFromFloat(t)
 	fv, err := strconv.ParseFloat(v, 64)
-	return tt, fv, err
+	return tt, fv, v, err
 }
 
 type histogramInfo struct {
@@ -714,6 +809,11 @@ type histogramInfo struct {
 	yMax    *data.Field
 	count   *data.Field
 	yLayout *data.Field
+
+	// Time	Count	Sum, one row per histogram sample
+	totalTime  *data.Field
+	totalCount *data.Field
+	sum        *data.Field
 }
 
 func newHistogramInfo() *histogramInfo {
@@ -723,12 +823,19 @@ func newHistogramInfo() *histogramInfo {
 		yMax:    data.NewFieldFromFieldType(data.FieldTypeFloat64, 0),
 		count:   data.NewFieldFromFieldType(data.FieldTypeFloat64, 0),
 		yLayout: data.NewFieldFromFieldType(data.FieldTypeInt8, 0),
+
+		totalTime:  data.NewFieldFromFieldType(data.FieldTypeTime, 0),
+		totalCount: data.NewFieldFromFieldType(data.FieldTypeFloat64, 0),
+		sum:        data.NewFieldFromFieldType(data.FieldTypeFloat64, 0),
 	}
 	hist.time.Name = "xMax"
 	hist.yMin.Name = "yMin"
 	hist.yMax.Name = "yMax"
 	hist.count.Name = "count"
 	hist.yLayout.Name = "yLayout"
+	hist.totalTime.Name = data.TimeSeriesTimeFieldName
+	hist.totalCount.Name = HistogramCountFieldName
+	hist.sum.Name = HistogramSumFieldName
 	return hist
 }
 
@@ -745,6 +852,7 @@ func readHistogram(iter *sdkjsoniter.Iterator, hist *histogramInfo) error {
 		return err
 	}
 	t := timeFromFloat(f)
+	var count, sum float64
 
 	// next object element
 	if _, err := iter.ReadArray(); err != nil {
@@ -757,11 +865,11 @@ func readHistogram(iter *sdkjsoniter.Iterator, hist *histogramInfo) error {
 		}
 		switch l1Field {
 		case "count":
-			if err = iter.Skip(); err != nil {
+			if count, err = readFloatFromString(iter); err != nil {
 				return err
 			}
 		case "sum":
-			if err = iter.Skip(); err != nil {
+			if sum, err = readFloatFromString(iter); err != nil {
 				return err
 			}
 
@@ -831,18 +939,15 @@ func readHistogram(iter *sdkjsoniter.Iterator, hist *histogramInfo) error {
 		return fmt.Errorf("expected to be done")
 	}
 
+	hist.totalTime.Append(t)
+	hist.totalCount.Append(count)
+	hist.sum.Append(sum)
 	return nil
 }
 
 func appendValueFromString(iter *sdkjsoniter.Iterator, field *data.Field) error {
-	var err error
-	var s string
-	if s, err = iter.ReadString(); err != nil {
-		return err
-	}
-
-	var v float64
-	if v, err = strconv.ParseFloat(s, 64); err != nil {
+	v, err := readFloatFromString(iter)
+	if err != nil {
 		return err
 	}
 
@@ -850,6 +955,14 @@ func appendValueFromString(iter *sdkjsoniter.Iterator, field *data.Field) error
 	return nil
 }
 
+func readFloatFromString(iter *sdkjsoniter.Iterator) (float64, error) {
+	s, err := iter.ReadString()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
 func readStream(iter *sdkjsoniter.Iterator) backend.DataResponse {
 	rsp := backend.DataResponse{}
 
@@ -1119,6 +1232,20 @@ streamField:
 	return parsedLabelsMap, structuredMetadataMap, nil
 }
 
+// setCustomMeta stores a value of the response data in the custom metadata of the frame. Values share one map, which
+// replaces the result type metadata.
+func setCustomMeta(frame *data.Frame, key string, v any) {
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	custom, ok := frame.Meta.Custom.(map[string]any)
+	if !ok {
+		custom = map[string]any{}
+		frame.Meta.Custom = custom
+	}
+	custom[key] = v
+}
+
 func resultTypeToCustomMeta(resultType string) map[string]string {
 	return map[string]string{"resultType": resultType}
 }
@@ -1,12 +1,15 @@
 package converter
 
 import (
+	"math"
 	"os"
 	"path"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	sdkjsoniter "github.com/grafana/grafana-plugin-sdk-go/data/utils/jsoniter"
 	"github.com/grafana/grafana-plugin-sdk-go/experimental"
 	jsoniter "github.com/json-iterator/go"
@@ -84,3 +87,105 @@ func TestTimeConversions(t *testing.T) {
 		time.Date(2033, time.May, 18, 3, 33, 20, 0, time.UTC),
 		ti)
 }
+
+func TestRawValues(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"a"},"values":[
+		[1641889530,"NaN"],[1641889531,"+Inf"],[1641889532,"-Inf"],[1641889533,"9007199254740993"]]}]}}`
+
+	t.Run("parses special values", func(t *testing.T) {
+		rsp := ReadPrometheusStyleResult(jsoniter.Parse(sdkjsoniter.ConfigDefault, strings.NewReader(body), 1024), Options{})
+		require.NoError(t, rsp.Error)
+		require.Len(t, rsp.Frames, 1)
+		require.Len(t, rsp.Frames[0].Fields, 2)
+
+		values := rsp.Frames[0].Fields[1]
+		require.True(t, math.IsNaN(values.At(0).(float64)))
+		require.True(t, math.IsInf(values.At(1).(float64), 1))
+		require.True(t, math.IsInf(values.At(2).(float64), -1))
+	})
+
+	t.Run("keeps raw values", func(t *testing.T) {
+		rsp := ReadPrometheusStyleResult(jsoniter.Parse(sdkjsoniter.ConfigDefault, strings.NewReader(body), 1024), Options{RawValues: true})
+		require.NoError(t, rsp.Error)
+		require.Len(t, rsp.Frames[0].Fields, 3)
+
+		raw := rsp.Frames[0].Fields[2]
+		require.Equal(t, RawValueFieldName, raw.Name)
+		require.Equal(t, "a", raw.Labels["job"])
+		require.Equal(t, []string{"NaN", "+Inf", "-Inf", "9007199254740993"}, []string{
+			raw.At(0).(string), raw.At(1).(string), raw.At(2).(string), raw.At(3).(string),
+		})
+	})
+}
+
+func TestHistogramTotals(t *testing.T) {
+	read := func(t *testing.T, opts Options) backend.DataResponse {
+		// Safe to disable, this is a test.
+		// nolint:gosec
+		f, err := os.Open(path.Join("testdata", "prom-matrix-histogram-no-labels.json"))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, f.Close()) }()
+		return ReadPrometheusStyleResult(jsoniter.Parse(sdkjsoniter.ConfigDefault, f, 1024), opts)
+	}
+
+	t.Run("returns only heatmap cells by default", func(t *testing.T) {
+		rsp := read(t, Options{})
+		require.NoError(t, rsp.Error)
+		require.Len(t, rsp.Frames, 1)
+		require.Equal(t, data.FrameType("heatmap-cells"), rsp.Frames[0].Meta.Type)
+	})
+
+	t.Run("adds count and sum of every sample", func(t *testing.T) {
+		rsp := read(t, Options{HistogramTotals: true})
+		require.NoError(t, rsp.Error)
+		require.Len(t, rsp.Frames, 2)
+
+		totals := rsp.Frames[1]
+		require.Equal(t, data.FrameTypeTimeSeriesMulti, totals.Meta.Type)
+		require.Len(t, totals.Fields, 3)
+		require.Equal(t, HistogramCountFieldName, totals.Fields[1].Name)
+		require.Equal(t, HistogramSumFieldName, totals.Fields[2].Name)
+		require.Equal(t, 7, totals.Rows())
+		require.Equal(t, time.Unix(1649963300, 0).UTC(), totals.Fields[0].At(0))
+		require.InDelta(t, 316.9547490576795, totals.Fields[1].At(0).(float64), 1e-9)
+		require.InDelta(t, 3.5039780556575875, totals.Fields[2].At(0).(float64), 1e-9)
+	})
+}
+
+func TestNullResult(t *testing.T) {
+	for _, resultType := range []string{"matrix", "vector", "scalar", "string", "streams"} {
+		t.Run(resultType, func(t *testing.T) {
+			body := `{"status":"success","data":{"resultType":"` + resultType + `","result":null}}`
+			rsp := ReadPrometheusStyleResult(jsoniter.Parse(sdkjsoniter.ConfigDefault, strings.NewReader(body), 1024), Options{})
+			require.NoError(t, rsp.Error)
+			require.Empty(t, rsp.Frames)
+		})
+	}
+
+	t.Run("before result type", func(t *testing.T) {
+		body := `{"status":"success","data":{"result":null,"resultType":"matrix"}}`
+		rsp := ReadPrometheusStyleResult(jsoniter.Parse(sdkjsoniter.ConfigDefault, strings.NewReader(body), 1024), Options{})
+		require.NoError(t, rsp.Error)
+		require.Empty(t, rsp.Frames)
+	})
+}
+
+func TestEnvelope(t *testing.T) {
+	t.Run("returns envelope of response without series", func(t *testing.T) {
+		body := `{"status":"success","warnings":["w1","w2"],"infos":["i1"],"data":{"resultType":"matrix","result":[],"stats":{"timings":{"evalTotalTime":0.5}},"explanation":{"name":"plan"}}}`
+		rsp, env := ReadPrometheusStyleResultEnvelope(jsoniter.Parse(sdkjsoniter.ConfigDefault, strings.NewReader(body), 1024), Options{})
+		require.NoError(t, rsp.Error)
+		require.Empty(t, rsp.Frames)
+		require.Equal(t, []string{"w1", "w2"}, env.Warnings)
+		require.Equal(t, []string{"i1"}, env.Infos)
+		require.Equal(t, map[string]any{"timings": map[string]any{"evalTotalTime": 0.5}}, env.Stats)
+		require.Equal(t, map[string]any{"name": "plan"}, env.Explanation)
+	})
+
+	t.Run("returns empty envelope without annotations", func(t *testing.T) {
+		body := `{"status":"success","data":{"resultType":"vector","result":[]}}`
+		rsp, env := ReadPrometheusStyleResultEnvelope(jsoniter.Parse(sdkjsoniter.ConfigDefault, strings.NewReader(body), 1024), Options{})
+		require.NoError(t, rsp.Error)
+		require.Equal(t, Envelope{}, env)
+	})
+}
@@ -23,6 +23,8 @@ type healthCheckSuccessRoundTripper struct {
 }
 type healthCheckFailRoundTripper struct {
 }
+type healthCheckErrorPageRoundTripper struct {
+}
 
 func (rt *healthCheckSuccessRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return &http.Response{
@@ -55,6 +57,17 @@ func (rt *healthCheckFailRoundTripper) RoundTrip(req *http.Request) (*http.Respo
 	}, nil
 }
 
+func (rt *healthCheckErrorPageRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:        "502 Bad Gateway",
+		StatusCode:    502,
+		Header:        http.Header{"Content-Type": {"text/html"}},
+		Body:          io.NopCloser(strings.NewReader("<html><body>502 Bad Gateway</body></html>")),
+		ContentLength: 0,
+		Request:       req,
+	}, nil
+}
+
 func (provider *healthCheckProvider[T]) New(opts ...httpclient.Options) (*http.Client, error) {
 	client := &http.Client{}
 	provider.RoundTripper = new(T)
@@ -109,6 +122,24 @@ func Test_healthcheck(t *testing.T) {
 		res, err := s.CheckHealth(context.Background(), req)
 		assert.NoError(t, err)
 		assert.Equal(t, backend.HealthStatusError, res.Status)
+		assert.Equal(t, "400 Bad Request - There was an error returned querying the Prometheus API.", res.Message)
+	})
+
+	t.Run("should return the status of error pages", func(t *testing.T) {
+		httpProvider := getMockProvider[*healthCheckErrorPageRoundTripper]()
+		s := &Service{
+			im: datasource.NewInstanceManager(newInstanceSettings(httpProvider, backend.NewLoggerWith("logger", "test"))),
+		}
+
+		req := &backend.CheckHealthRequest{
+			PluginContext: getPluginContext(),
+			Headers:       nil,
+		}
+
+		res, err := s.CheckHealth(context.Background(), req)
+		assert.NoError(t, err)
+		assert.Equal(t, backend.HealthStatusError, res.Status)
+		assert.Equal(t, "502 Bad Gateway: <html><body>502 Bad Gateway</body></html> - There was an error returned querying the Prometheus API.", res.Message)
 	})
 }
 
@@ -103,6 +103,9 @@ const (
 
 var safeResolution = 11000
 
+// defaultStep is the step of queries without interval and min step, like the default min interval of Parse.
+const defaultStep = 15 * time.Second
+
 // QueryModel includes both the common and specific values
 type QueryModel struct {
 	PrometheusQueryProperties `json:",inline"`
@@ -140,6 +143,33 @@ type Query struct {
 	ExemplarQuery bool
 	UtcOffsetSec  int64
 	Scope         Scope
+	// Timeout is sent as the evaluation timeout parameter of the query. Zero means the server default is used.
+	Timeout time.Duration
+	// LookbackDelta overrides the lookback period of the query for staleness. Zero means the server default is used.
+	LookbackDelta time.Duration
+	// PartialResponse and Dedup enable the partial_response and dedup parameters of Thanos Query. They are only sent
+	// when enabled, as Prometheus does not know them.
+	PartialResponse bool
+	Dedup           bool
+	// MaxSourceResolution selects downsampled Thanos blocks, e.g. 5m or 1h. Zero means raw data is used.
+	MaxSourceResolution time.Duration
+	// Method overrides the HTTP method of the client for this query, e.g. POST for long expressions. Empty means the
+	// method of the client is used.
+	Method string
+	// Stats requests the query statistics of Prometheus, like the number of samples loaded.
+	Stats bool
+	// Explain requests the query plan from engines that support it, like the Thanos engine. Prometheus ignores it.
+	Explain bool
+	// Limit caps the number of series returned by range and instant queries, supported since Prometheus 2.54. Zero
+	// means no limit. Truncated results come with a warning.
+	Limit int
+	// ExtraParams are sent with range and instant queries in addition to the standard parameters, e.g. for
+	// experimental Prometheus features. They cannot override query, start, end, step, time or parameters set from
+	// the other fields.
+	ExtraParams map[string]string
+	// QueryTags are sent in the X-Query-Tags header of range, instant and exemplar queries as key=value pairs, so
+	// multi-tenant stores like Mimir can attribute the cost of the query. No header is sent without tags.
+	QueryTags map[string]string
 }
 
 type Scope struct {
@@ -225,6 +255,39 @@ func (query *Query) TimeRange() TimeRange {
 	}
 }
 
+// ResolveStep sets the step of the query like Parse computes it for a panel with the given min step, interval in
+// milliseconds and max data points, so callers do not need to compute the step themselves. Like in Parse, the min step
+// takes precedence over the interval, and the default of 15s is used without both.
+func (query *Query) ResolveStep(minStep time.Duration, intervalMs, maxDataPoints int64) {
+	minInterval := minStep
+	if minInterval <= 0 {
+		minInterval = time.Duration(intervalMs) * time.Millisecond
+	}
+	if minInterval <= 0 {
+		minInterval = defaultStep
+	}
+	query.Step = CalculateStep(query.Start, query.End, minInterval, maxDataPoints)
+}
+
+// CalculateStep returns the step of a range query from start to end like Parse: the range divided by maxDataPoints,
+// or 1500 points for zero, rounded like Grafana rounds $__interval. The step is never smaller than minStep, and is
+// increased for ranges that would exceed the resolution limit of Prometheus.
+func CalculateStep(start, end time.Time, minStep time.Duration, maxDataPoints int64) time.Duration {
+	return resolveInterval(intervalv2.NewCalculator(), backend.TimeRange{From: start, To: end}, minStep, maxDataPoints)
+}
+
+// resolveInterval returns the interval of the time range for maxDataPoints, at least minInterval and large enough to
+// stay within safeResolution points.
+func resolveInterval(intervalCalculator intervalv2.Calculator, tr backend.TimeRange, minInterval time.Duration, maxDataPoints int64) time.Duration {
+	calculatedInterval := intervalCalculator.Calculate(tr, minInterval, maxDataPoints)
+	safeInterval := intervalCalculator.CalculateSafeInterval(tr, int64(safeResolution))
+
+	if calculatedInterval.Value > safeInterval.Value {
+		return calculatedInterval.Value
+	}
+	return safeInterval.Value
+}
+
 func calculatePrometheusInterval(
 	queryInterval, dsScrapeInterval string,
 	intervalMs, intervalFactor int64,
@@ -244,13 +307,7 @@ func calculatePrometheusInterval(
 	if err != nil {
 		return time.Duration(0), err
 	}
-	calculatedInterval := intervalCalculator.Calculate(query.TimeRange, minInterval, query.MaxDataPoints)
-	safeInterval := intervalCalculator.CalculateSafeInterval(query.TimeRange, int64(safeResolution))
-
-	adjustedInterval := safeInterval.Value
-	if calculatedInterval.Value > safeInterval.Value {
-		adjustedInterval = calculatedInterval.Value
-	}
+	adjustedInterval := resolveInterval(intervalCalculator, query.TimeRange, minInterval, query.MaxDataPoints)
 
 	// here is where we compare for $__rate_interval or ${__rate_interval}
 	if originalQueryInterval == varRateInterval || originalQueryInterval == varRateIntervalAlt {
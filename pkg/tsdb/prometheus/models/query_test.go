@@ -782,3 +782,61 @@ func TestAlignTimeRange(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveStep(t *testing.T) {
+	start := time.Unix(1664816826, 0)
+
+	tests := []struct {
+		name          string
+		r             time.Duration
+		minStep       time.Duration
+		intervalMs    int64
+		maxDataPoints int64
+		expected      time.Duration
+	}{
+		{name: "divides range by max data points", r: time.Hour, minStep: 0, intervalMs: 2000, maxDataPoints: 1800, expected: 2 * time.Second},
+		{name: "uses min step larger than interval", r: time.Hour, minStep: 15 * time.Second, intervalMs: 2000, maxDataPoints: 1800, expected: 15 * time.Second},
+		{name: "uses interval without min step", r: time.Hour, minStep: 0, intervalMs: 60000, maxDataPoints: 1800, expected: time.Minute},
+		{name: "prefers min step to interval", r: time.Hour, minStep: 30 * time.Second, intervalMs: 60000, maxDataPoints: 1800, expected: 30 * time.Second},
+		{name: "uses default without interval and min step", r: time.Hour, minStep: 0, intervalMs: 0, maxDataPoints: 1800, expected: 15 * time.Second},
+		{name: "uses 1500 points without max data points", r: 24 * time.Hour, minStep: 0, intervalMs: 1, maxDataPoints: 0, expected: time.Minute},
+		{name: "uses safe interval for very large ranges", r: 365 * 24 * time.Hour, minStep: 0, intervalMs: 1000, maxDataPoints: 100000, expected: time.Hour},
+		{name: "keeps interval above safe interval", r: 365 * 24 * time.Hour, minStep: 0, intervalMs: 86400000, maxDataPoints: 1800, expected: 24 * time.Hour},
+		{name: "ignores empty range", r: 0, minStep: 0, intervalMs: 1000, maxDataPoints: 1800, expected: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &models.Query{Start: start, End: start.Add(tt.r), RangeQuery: true}
+			q.ResolveStep(tt.minStep, tt.intervalMs, tt.maxDataPoints)
+			require.Equal(t, tt.expected, q.Step)
+		})
+	}
+
+	t.Run("matches the step of Parse", func(t *testing.T) {
+		for _, r := range []time.Duration{time.Minute, time.Hour, 7 * 24 * time.Hour, 365 * 24 * time.Hour} {
+			for _, minStep := range []string{"", "10s", "5m"} {
+				for _, intervalMs := range []int64{0, 1000, 60000} {
+					for _, maxDataPoints := range []int64{0, 100, 1800, 100000} {
+						q := backend.DataQuery{
+							JSON:          []byte(fmt.Sprintf(`{"expr": "up", "range": true, "interval": %q, "intervalMs": %d}`, minStep, intervalMs)),
+							TimeRange:     backend.TimeRange{From: start, To: start.Add(r)},
+							MaxDataPoints: maxDataPoints,
+						}
+						parsed, err := models.Parse(q, "", intervalCalculator, false, false)
+						require.NoError(t, err)
+
+						var step time.Duration
+						if minStep != "" {
+							step, err = time.ParseDuration(minStep)
+							require.NoError(t, err)
+						}
+						resolved := &models.Query{Start: q.TimeRange.From, End: q.TimeRange.To, RangeQuery: true}
+						resolved.ResolveStep(step, intervalMs, maxDataPoints)
+						require.Equal(t, parsed.Step, resolved.Step, "range %s, min step %q, interval %dms, %d points", r, minStep, intervalMs, maxDataPoints)
+					}
+				}
+			}
+		}
+	})
+}
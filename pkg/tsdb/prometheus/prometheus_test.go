@@ -33,7 +33,8 @@ func (rt *fakeRoundtripper) RoundTrip(req *http.Request) (*http.Response, error)
 	rt.Req = req
 	reqBody, _ := io.ReadAll(req.Body)
 	respHeader := make(http.Header)
-	if req.Header.Get("Accept-Encoding") == "gzip" {
+	// The client always accepts gzip, so only echoed bodies that are gzipped are marked as such.
+	if req.Header.Get("Accept-Encoding") == "gzip" && bytes.HasPrefix(reqBody, []byte{0x1f, 0x8b}) {
 		respHeader.Add("Content-Encoding", "gzip")
 	}
 	return &http.Response{
@@ -113,8 +114,10 @@ func TestService(t *testing.T) {
 				require.Equal(
 					t,
 					http.Header{
+						"Accept-Encoding": {"gzip"},
 						"Content-Type":    {"application/x-www-form-urlencoded"},
 						"Idempotency-Key": []string(nil),
+						"User-Agent":      {"Grafana/prometheus-client"},
 					},
 					f.Roundtripper.Req.Header)
 				require.Equal(t, http.MethodPost, f.Roundtripper.Req.Method)
@@ -2,6 +2,7 @@ package querydata
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -66,14 +67,18 @@ func New(
 		httpMethod = http.MethodPost
 	}
 
-	promClient := client.NewClient(httpClient, httpMethod, settings.URL)
+	tracer := tracing.DefaultTracer()
+	promClient, err := client.NewClient(httpClient, httpMethod, settings.URL, client.WithTracer(tracer))
+	if err != nil {
+		return nil, err
+	}
 
 	// standard deviation sampler is the default for backwards compatibility
 	exemplarSampler := exemplar.NewStandardDeviationSampler
 
 	return &QueryData{
 		intervalCalculator: intervalv2.NewCalculator(),
-		tracer:             tracing.DefaultTracer(),
+		tracer:             tracer,
 		log:                plog,
 		client:             promClient,
 		TimeInterval:       timeInterval,
@@ -160,10 +165,7 @@ func (s *QueryData) fetch(ctx context.Context, client *client.Client, q *models.
 func (s *QueryData) rangeQuery(ctx context.Context, c *client.Client, q *models.Query, enablePrometheusDataplaneFlag bool) backend.DataResponse {
 	res, err := c.QueryRange(ctx, q)
 	if err != nil {
-		return backend.DataResponse{
-			Error:  err,
-			Status: backend.StatusBadGateway,
-		}
+		return queryErrorResponse(err)
 	}
 
 	defer func() {
@@ -179,17 +181,7 @@ func (s *QueryData) rangeQuery(ctx context.Context, c *client.Client, q *models.
 func (s *QueryData) instantQuery(ctx context.Context, c *client.Client, q *models.Query, enablePrometheusDataplaneFlag bool) backend.DataResponse {
 	res, err := c.QueryInstant(ctx, q)
 	if err != nil {
-		return backend.DataResponse{
-			Error:  err,
-			Status: backend.StatusBadGateway,
-		}
-	}
-
-	// This is only for health check fall back scenario
-	if res.StatusCode != 200 && q.RefId == "__healthcheck__" {
-		return backend.DataResponse{
-			Error: fmt.Errorf(res.Status),
-		}
+		return queryErrorResponse(err)
 	}
 
 	defer func() {
@@ -219,6 +211,21 @@ func (s *QueryData) exemplarQuery(ctx context.Context, c *client.Client, q *mode
 	return s.parseResponse(ctx, q, res, enablePrometheusDataplaneFlag)
 }
 
+// queryErrorResponse keeps the status code of errors returned by Prometheus, other errors mean we could not get a
+// response from the data source at all.
+func queryErrorResponse(err error) backend.DataResponse {
+	status := backend.StatusBadGateway
+	var promErr *client.PrometheusError
+	if errors.As(err, &promErr) {
+		status = backend.Status(promErr.Status)
+	}
+
+	return backend.DataResponse{
+		Error:  err,
+		Status: status,
+	}
+}
+
 func (s *QueryData) trace(ctx context.Context, q *models.Query) (context.Context, func()) {
 	return utils.StartTrace(ctx, s.tracer, "datasource.prometheus",
 		attribute.String("expr", q.Expr),
@@ -36,9 +36,14 @@ func New(
 		httpMethod = http.MethodPost
 	}
 
+	promClient, err := client.NewClient(httpClient, httpMethod, settings.URL)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Resource{
 		log:        plog,
-		promClient: client.NewClient(httpClient, httpMethod, settings.URL),
+		promClient: promClient,
 	}, nil
 }
 